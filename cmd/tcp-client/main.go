@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"github.com/aluka-7/game-gateway/tcp"
 	pb "github.com/aluka-7/game-gateway/tcp/proto"
 	"github.com/golang/protobuf/proto"
@@ -15,36 +16,69 @@ const (
 	gameAlias = "wingo"
 )
 
+var (
+	// 握手密钥需与网关配置 link.secret 或 link.secrets 中的密钥一致
+	secret   = flag.String("secret", "", "link handshake secret")
+	instance = flag.String("instance", "tcp-client-dev", "game server instance id")
+	// 网关 link.mode 为 connect 时，等待网关连接到该地址
+	listen = flag.String("listen", "", "wait for the gateway to connect on this address")
+)
+
 func main() {
+	flag.Parse()
+	if *secret == "" {
+		log.Fatal("缺少 -secret 参数")
+	}
+	if *listen != "" {
+		l, err := net.Listen("tcp", *listen)
+		if err != nil {
+			log.Fatal("监听失败:", err)
+		}
+		log.Println("等待网关连接:", *listen)
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				log.Fatal("接受连接失败:", err)
+			}
+			log.Println("✅ 网关已连接:", conn.RemoteAddr())
+			run(conn)
+		}
+	}
 	for {
-		run()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			log.Println("连接失败:", err)
+		} else {
+			log.Println("✅ 已连接:", addr)
+			run(conn)
+		}
 		log.Println("连接断开，5秒后重连...")
 		time.Sleep(5 * time.Second)
 	}
 }
 
-func run() {
-	conn, err := net.Dial("tcp", addr)
-	if err != nil {
-		log.Println("连接失败:", err)
-		return
-	}
+func run(conn net.Conn) {
 	defer conn.Close()
 
-	log.Println("✅ 已连接:", addr)
-
-	// 1️⃣ 发送 alias
-	_, err = conn.Write([]byte(gameAlias + "\n"))
+	// 1️⃣ 握手
+	frame, err := tcp.EncodeHandshake(tcp.NewHandshake(gameAlias, *instance, *secret))
 	if err != nil {
-		log.Println("发送 alias 失败:", err)
+		log.Println("编码握手失败:", err)
+		return
+	}
+	if _, err = conn.Write(frame); err != nil {
+		log.Println("发送握手失败:", err)
 		return
 	}
-	log.Println("➡️ 已注册", gameAlias)
-
 	reader := bufio.NewReader(conn)
+	if err = tcp.ReadHandshakeReply(reader); err != nil {
+		log.Println("握手被拒绝:", err)
+		return
+	}
+	log.Println("➡️ 已注册", gameAlias)
 
 	// 2️⃣ 启动读协程
-	go readLoop(reader)
+	go readLoop(conn, reader)
 
 	// 3️⃣ 定时发送测试包
 	ticker := time.NewTicker(5 * time.Second)
@@ -82,7 +116,7 @@ func sendMessage(conn net.Conn, msg *pb.TcpMessage) error {
 	return err
 }
 
-func readLoop(reader *bufio.Reader) {
+func readLoop(conn net.Conn, reader *bufio.Reader) {
 	for {
 		payload, err := tcp.ReadFrame(reader)
 		if err != nil {
@@ -96,6 +130,15 @@ func readLoop(reader *bufio.Reader) {
 			continue
 		}
 
+		// 链路心跳，不回复会被网关断开
+		if packet.Server == "system" && packet.Event == tcp.EventPing {
+			if err := sendMessage(conn, &pb.TcpMessage{Server: "system", Event: tcp.EventPong, Seq: packet.Seq}); err != nil {
+				log.Println("❌ 回复心跳失败:", err)
+				return
+			}
+			continue
+		}
+
 		log.Printf("⬅️ 收到:server=%s event=%s seq=%d code=%d msg=%s data=%s\n",
 			packet.Server,
 			packet.Event,
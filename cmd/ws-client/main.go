@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/aluka-7/game-gateway/ws"
 	"github.com/golang-jwt/jwt/v5"
@@ -14,14 +15,28 @@ import (
 var (
 	target    = "ws://127.0.0.1:9009"
 	heartbeat = 10 * time.Second
+	// tokenId 需与 redis 中 system:user:token:10001 保存的值一致
+	tokenId = "ws-client-dev"
+
+	// 签名密钥需与网关配置 jwt.keys 中的 HMAC 密钥一致
+	secret = flag.String("secret", "", "jwt HMAC secret")
+	kid    = flag.String("kid", "", "jwt kid header")
+	// 网关开启 compression.enable 后协商 permessage-deflate
+	compress = flag.Bool("compress", false, "enable permessage-deflate")
 )
 
 func main() {
+	flag.Parse()
+	if *secret == "" {
+		log.Fatal("缺少 -secret 参数")
+	}
 	u, _ := url.Parse(target)
 
 	log.Println(fmt.Sprintf("Connecting to: %s", u.String()))
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = *compress
+	conn, _, err := dialer.Dial(u.String(), nil)
 	if err != nil {
 		log.Fatal("连接失败:", err)
 	}
@@ -41,12 +56,17 @@ func main() {
 			Id: 10001,
 		},
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       tokenId,
 			Subject:  "test-user",
 			IssuedAt: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("kX9Gxcd1-@0eV-*1"))
+	jt := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if *kid != "" {
+		jt.Header["kid"] = *kid
+	}
+	token, err := jt.SignedString([]byte(*secret))
 	if err != nil {
 		log.Fatal("token生成失败:", err)
 	}
@@ -2,21 +2,41 @@ package conn
 
 import (
 	"github.com/panjf2000/gnet/v2"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
 	UID           int64
+	SessionId     string // 网关会话id
+	DeviceId      string // 登录设备id，来自 token
 	Conn          gnet.Conn
-	Send          chan []byte
-	LastHeartbeat int64 // 最后心跳时间戳
+	Queue         Queue // 下行消息发送队列
+	LastHeartbeat int64 // 最后心跳时间戳，通过 Touch/Heartbeat 并发访问
+
+	server string // 绑定的游戏服务，由 Members 维护
+	index  int    // 在 Members 成员列表中的位置
+
+	rooms   map[string]struct{} // 加入的房间，由 Rooms 维护
+	dropped bool                // 已移出全部房间，不能再加入
 }
 
-func NewClient(uid int64, conn gnet.Conn) *Client {
+func NewClient(uid int64, sessionId, deviceId string, conn gnet.Conn) *Client {
 	return &Client{
 		UID:           uid,
+		SessionId:     sessionId,
+		DeviceId:      deviceId,
 		Conn:          conn,
-		Send:          make(chan []byte, 64),
 		LastHeartbeat: time.Now().Unix(),
 	}
 }
+
+// Touch 收到任意帧后刷新心跳时间
+func (c *Client) Touch() {
+	atomic.StoreInt64(&c.LastHeartbeat, time.Now().Unix())
+}
+
+// Heartbeat 返回最后心跳时间戳
+func (c *Client) Heartbeat() int64 {
+	return atomic.LoadInt64(&c.LastHeartbeat)
+}
@@ -1,12 +1,24 @@
 package conn
 
 import (
+	"errors"
 	"sync"
 )
 
+// LoginPolicy 同一用户多次登录时的处理策略
+type LoginPolicy string
+
+const (
+	PolicyKick   LoginPolicy = "kick"   // 踢掉旧会话（默认）
+	PolicyReject LoginPolicy = "reject" // 拒绝新会话
+	PolicyMulti  LoginPolicy = "multi"  // 按设备允许多个会话同时在线
+)
+
+var ErrSessionExists = errors.New("session already exists")
+
 type Manager struct {
 	mu    sync.RWMutex
-	conns map[int64]*Client
+	conns map[int64][]*Client // uid -> 会话列表，按登录先后排序
 }
 
 // ConnItem is a snapshot item for safe iteration outside lock.
@@ -17,34 +29,83 @@ type ConnItem struct {
 
 func NewManager() *Manager {
 	return &Manager{
-		conns: make(map[int64]*Client),
+		conns: make(map[int64][]*Client),
 	}
 }
 
-func (m *Manager) Set(uid int64, c *Client) {
+// Add 按登录策略加入会话，返回需要踢下线的旧会话
+//
+// multi 策略下同一设备的旧会话会被替换，超过 max 个会话时踢掉最早登录的会话。
+func (m *Manager) Add(c *Client, policy LoginPolicy, max int) (kicked []*Client, err error) {
 	m.mu.Lock()
-	m.conns[uid] = c
-	m.mu.Unlock()
+	defer m.mu.Unlock()
+
+	olds := m.conns[c.UID]
+	switch policy {
+	case PolicyReject:
+		if len(olds) > 0 {
+			return nil, ErrSessionExists
+		}
+		m.conns[c.UID] = []*Client{c}
+	case PolicyMulti:
+		if max < 1 {
+			max = 1
+		}
+		sessions := make([]*Client, 0, len(olds)+1)
+		for _, old := range olds {
+			if old.DeviceId == c.DeviceId {
+				kicked = append(kicked, old)
+				continue
+			}
+			sessions = append(sessions, old)
+		}
+		for len(sessions) >= max {
+			kicked = append(kicked, sessions[0])
+			sessions = sessions[1:]
+		}
+		m.conns[c.UID] = append(sessions, c)
+	default:
+		kicked = olds
+		m.conns[c.UID] = []*Client{c}
+	}
+	return kicked, nil
 }
 
-func (m *Manager) Get(uid int64) (*Client, bool) {
+// Get 返回用户的全部会话
+func (m *Manager) Get(uid int64) ([]*Client, bool) {
 	m.mu.RLock()
-	c, ok := m.conns[uid]
+	sessions := m.conns[uid]
 	m.mu.RUnlock()
-	return c, ok
+	return sessions, len(sessions) > 0
 }
 
-func (m *Manager) Remove(uid int64) {
+// Remove 移除用户的指定会话
+func (m *Manager) Remove(uid int64, c *Client) {
 	m.mu.Lock()
-	delete(m.conns, uid)
-	m.mu.Unlock()
+	defer m.mu.Unlock()
+	sessions := m.conns[uid]
+	for i, s := range sessions {
+		if s != c {
+			continue
+		}
+		if len(sessions) == 1 {
+			delete(m.conns, uid)
+			return
+		}
+		rest := make([]*Client, 0, len(sessions)-1)
+		rest = append(rest, sessions[:i]...)
+		m.conns[uid] = append(rest, sessions[i+1:]...)
+		return
+	}
 }
 
 func (m *Manager) Range(fn func(uid int64, cli *Client)) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	for uid, cli := range m.conns {
-		fn(uid, cli)
+	for uid, sessions := range m.conns {
+		for _, cli := range sessions {
+			fn(uid, cli)
+		}
 	}
 }
 
@@ -52,8 +113,10 @@ func (m *Manager) Range(fn func(uid int64, cli *Client)) {
 func (m *Manager) Snapshot() []ConnItem {
 	m.mu.RLock()
 	items := make([]ConnItem, 0, len(m.conns))
-	for uid, cli := range m.conns {
-		items = append(items, ConnItem{UID: uid, Client: cli})
+	for uid, sessions := range m.conns {
+		for _, cli := range sessions {
+			items = append(items, ConnItem{UID: uid, Client: cli})
+		}
 	}
 	m.mu.RUnlock()
 	return items
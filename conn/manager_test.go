@@ -0,0 +1,63 @@
+package conn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestManagerPolicies(t *testing.T) {
+	m := NewManager()
+	a := NewClient(1, "a", "phone", nil)
+	b := NewClient(1, "b", "pad", nil)
+
+	if kicked, _ := m.Add(a, PolicyKick, 0); len(kicked) != 0 {
+		t.Fatalf("first login kicked %d sessions", len(kicked))
+	}
+	if kicked, _ := m.Add(b, PolicyKick, 0); len(kicked) != 1 || kicked[0] != a {
+		t.Fatalf("kick policy: unexpected kicked sessions %v", kicked)
+	}
+	if _, err := m.Add(a, PolicyReject, 0); !errors.Is(err, ErrSessionExists) {
+		t.Fatalf("reject policy: got %v, want %v", err, ErrSessionExists)
+	}
+	if sessions, _ := m.Get(1); len(sessions) != 1 || sessions[0] != b {
+		t.Fatalf("reject policy changed sessions: %v", sessions)
+	}
+	m.Remove(1, b)
+	if _, ok := m.Get(1); ok {
+		t.Fatal("session not removed")
+	}
+}
+
+func TestManagerMulti(t *testing.T) {
+	m := NewManager()
+	phone := NewClient(1, "1", "phone", nil)
+	pad := NewClient(1, "2", "pad", nil)
+	pc := NewClient(1, "3", "pc", nil)
+	phone2 := NewClient(1, "4", "phone", nil)
+
+	for _, c := range []*Client{phone, pad} {
+		if kicked, _ := m.Add(c, PolicyMulti, 2); len(kicked) != 0 {
+			t.Fatalf("device %s kicked %d sessions", c.DeviceId, len(kicked))
+		}
+	}
+	// 超过上限踢掉最早登录的会话
+	if kicked, _ := m.Add(pc, PolicyMulti, 2); len(kicked) != 1 || kicked[0] != phone {
+		t.Fatalf("limit: unexpected kicked sessions %v", kicked)
+	}
+	// 同一设备重复登录替换旧会话
+	if kicked, _ := m.Add(phone2, PolicyMulti, 3); len(kicked) != 0 {
+		t.Fatalf("new device: unexpected kicked sessions %v", kicked)
+	}
+	pad2 := NewClient(1, "5", "pad", nil)
+	if kicked, _ := m.Add(pad2, PolicyMulti, 3); len(kicked) != 1 || kicked[0] != pad {
+		t.Fatalf("same device: unexpected kicked sessions %v", kicked)
+	}
+	sessions, _ := m.Get(1)
+	if len(sessions) != 3 {
+		t.Fatalf("unexpected session count: got %d, want %d", len(sessions), 3)
+	}
+	m.Remove(1, pc)
+	if sessions, _ = m.Get(1); len(sessions) != 2 || sessions[0] != phone2 || sessions[1] != pad2 {
+		t.Fatalf("unexpected sessions after remove: %v", sessions)
+	}
+}
@@ -0,0 +1,67 @@
+package conn
+
+import (
+	"slices"
+	"sync"
+)
+
+// Members 游戏服务到已绑定会话的索引，广播时只遍历对应服务的成员
+type Members struct {
+	mu    sync.RWMutex
+	games map[string][]*Client
+}
+
+func NewMembers() *Members {
+	return &Members{games: make(map[string][]*Client)}
+}
+
+// Bind 将会话绑定到 server，已绑定其他服务时先移出
+func (m *Members) Bind(c *Client, server string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c.server == server {
+		return
+	}
+	m.remove(c)
+	c.server, c.index = server, len(m.games[server])
+	m.games[server] = append(m.games[server], c)
+}
+
+// Unbind 会话关闭后移出索引
+func (m *Members) Unbind(c *Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(c)
+}
+
+// remove 与最后一个成员交换位置后删除
+func (m *Members) remove(c *Client) {
+	if c.server == "" {
+		return
+	}
+	list := m.games[c.server]
+	last := len(list) - 1
+	list[c.index] = list[last]
+	list[c.index].index = c.index
+	list[last] = nil
+	if last == 0 {
+		delete(m.games, c.server)
+	} else {
+		m.games[c.server] = list[:last]
+	}
+	c.server, c.index = "", 0
+}
+
+// Snapshot 返回 server 全部成员的副本，遍历时不持有索引锁，广播期间不阻塞绑定与解绑
+func (m *Members) Snapshot(server string) []*Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.games[server])
+}
+
+// Count 返回 server 的成员数
+func (m *Members) Count(server string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.games[server])
+}
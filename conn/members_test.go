@@ -0,0 +1,84 @@
+package conn
+
+import (
+	"strconv"
+	"testing"
+)
+
+func members(m *Members, server string) map[*Client]bool {
+	got := make(map[*Client]bool)
+	for _, c := range m.Snapshot(server) {
+		got[c] = true
+	}
+	return got
+}
+
+func TestMembers(t *testing.T) {
+	m := NewMembers()
+	a := NewClient(1, "a", "", nil)
+	b := NewClient(2, "b", "", nil)
+	c := NewClient(3, "c", "", nil)
+	m.Bind(a, "wingo")
+	m.Bind(b, "wingo")
+	m.Bind(c, "wingo")
+	m.Bind(c, "wingo")
+	if m.Count("wingo") != 3 {
+		t.Fatalf("got %d members", m.Count("wingo"))
+	}
+
+	// 切换服务后从原服务移出
+	m.Bind(a, "crash")
+	if got := members(m, "wingo"); len(got) != 2 || !got[b] || !got[c] {
+		t.Fatalf("unexpected wingo members %v", got)
+	}
+	if got := members(m, "crash"); len(got) != 1 || !got[a] {
+		t.Fatalf("unexpected crash members %v", got)
+	}
+
+	m.Unbind(b)
+	m.Unbind(b)
+	if got := members(m, "wingo"); len(got) != 1 || !got[c] {
+		t.Fatalf("unexpected wingo members %v", got)
+	}
+	m.Unbind(c)
+	m.Unbind(a)
+	if len(m.games) != 0 {
+		t.Fatalf("empty games not removed: %v", m.games)
+	}
+}
+
+// BenchmarkMembersBind 10 万个会话分布在 20 个服务之间切换
+func BenchmarkMembersBind(b *testing.B) {
+	const conns, games = 100000, 20
+	m := NewMembers()
+	clients := make([]*Client, conns)
+	names := make([]string, games)
+	for i := range names {
+		names[i] = "game-" + strconv.Itoa(i)
+	}
+	for i := range clients {
+		clients[i] = NewClient(int64(i+1), "", "", nil)
+		m.Bind(clients[i], names[i%games])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Bind(clients[i%conns], names[(i/conns+i+1)%games])
+	}
+}
+
+func TestMembersSnapshot(t *testing.T) {
+	m := NewMembers()
+	a := NewClient(1, "a", "", nil)
+	b := NewClient(2, "b", "", nil)
+	m.Bind(a, "wingo")
+	m.Bind(b, "wingo")
+
+	// 遍历副本时可以修改索引，副本不受影响
+	snapshot := m.Snapshot("wingo")
+	for _, c := range snapshot {
+		m.Unbind(c)
+	}
+	if len(snapshot) != 2 || m.Count("wingo") != 0 {
+		t.Fatalf("snapshot %d members, %d left", len(snapshot), m.Count("wingo"))
+	}
+}
@@ -0,0 +1,67 @@
+package conn
+
+import "sync"
+
+// Overflow 发送队列已满时的处理策略
+type Overflow string
+
+const (
+	OverflowDropOldest Overflow = "drop_oldest" // 丢弃最早的消息
+	OverflowDropNewest Overflow = "drop_newest" // 丢弃新消息
+	OverflowDisconnect Overflow = "disconnect"  // 断开连接
+)
+
+// Queue 单个会话的有界发送队列，同一时间只有一批消息在发送中
+type Queue struct {
+	mu       sync.Mutex
+	frames   [][]byte
+	flushing bool
+}
+
+// Push 加入一帧，队列已满时按 policy 处理，返回被丢弃的消息数；overflow 为 true 表示需要断开连接
+func (q *Queue) Push(frame []byte, size int, policy Overflow) (dropped int, overflow bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.frames) >= size {
+		switch policy {
+		case OverflowDropNewest:
+			return 1, false
+		case OverflowDisconnect:
+			return 0, true
+		default:
+			// 配置缩小后可能一次丢弃多条
+			dropped = len(q.frames) - size + 1
+			q.frames = append(q.frames[:0], q.frames[dropped:]...)
+		}
+	}
+	q.frames = append(q.frames, frame)
+	return dropped, false
+}
+
+// Take 取出全部待发送的帧并标记为发送中，已有一批在发送中或队列为空时返回 nil
+func (q *Queue) Take() [][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.flushing || len(q.frames) == 0 {
+		return nil
+	}
+	frames := q.frames
+	q.frames = nil
+	q.flushing = true
+	return frames
+}
+
+// Done 一批消息发送完成，返回队列中是否还有待发送的帧
+func (q *Queue) Done() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.flushing = false
+	return len(q.frames) > 0
+}
+
+// Len 待发送的帧数，不含发送中的一批
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.frames)
+}
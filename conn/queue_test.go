@@ -0,0 +1,55 @@
+package conn
+
+import "testing"
+
+func TestQueueOverflow(t *testing.T) {
+	cases := []struct {
+		policy   Overflow
+		want     []string
+		dropped  int
+		overflow bool
+	}{
+		{OverflowDropOldest, []string{"b", "c"}, 1, false},
+		{OverflowDropNewest, []string{"a", "b"}, 1, false},
+		{OverflowDisconnect, []string{"a", "b"}, 0, true},
+	}
+	for _, c := range cases {
+		var q Queue
+		q.Push([]byte("a"), 2, c.policy)
+		q.Push([]byte("b"), 2, c.policy)
+		dropped, overflow := q.Push([]byte("c"), 2, c.policy)
+		if dropped != c.dropped || overflow != c.overflow {
+			t.Errorf("%s: dropped=%d overflow=%v", c.policy, dropped, overflow)
+		}
+		frames := q.Take()
+		if len(frames) != len(c.want) {
+			t.Fatalf("%s: got %d frames", c.policy, len(frames))
+		}
+		for i, f := range frames {
+			if string(f) != c.want[i] {
+				t.Errorf("%s: frame %d = %s, want %s", c.policy, i, f, c.want[i])
+			}
+		}
+	}
+}
+
+func TestQueueSingleFlush(t *testing.T) {
+	var q Queue
+	q.Push([]byte("a"), 8, OverflowDropOldest)
+	if frames := q.Take(); len(frames) != 1 {
+		t.Fatalf("got %d frames", len(frames))
+	}
+	q.Push([]byte("b"), 8, OverflowDropOldest)
+	if frames := q.Take(); frames != nil {
+		t.Fatal("took frames while flushing")
+	}
+	if !q.Done() {
+		t.Fatal("pending frames not reported")
+	}
+	if frames := q.Take(); len(frames) != 1 || string(frames[0]) != "b" {
+		t.Fatalf("unexpected frames %q", frames)
+	}
+	if q.Done() || q.Take() != nil {
+		t.Fatal("queue not empty")
+	}
+}
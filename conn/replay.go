@@ -0,0 +1,189 @@
+package conn
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultOutboxSize = 128
+	defaultResumeTTL  = 60 * time.Second
+	// maxResumeTokens 每个用户保留的重连凭证数量，超出时作废最早签发的凭证
+	maxResumeTokens = 8
+)
+
+// ResumeToken 断线重连凭证对应的登录信息
+type ResumeToken struct {
+	UID      int64
+	DeviceId string
+	TokenId  string // 签发凭证时的 jwt-id，恢复时校验是否已被吊销
+}
+
+type outboxItem struct {
+	seq     int64
+	payload []byte
+}
+
+// Outbox 用户最近的下行消息，按网关序号保存，断线重连后补发缺失部分
+type Outbox struct {
+	mu       sync.Mutex
+	seq      int64 // 最近一条消息的网关序号
+	items    []outboxItem
+	head     int // 最早一条消息的位置
+	size     int
+	tokens   []string // 关联的重连凭证
+	expireAt int64    // 全部会话断开后的过期时间，0 表示在线
+}
+
+func newOutbox(size int) *Outbox {
+	return &Outbox{items: make([]outboxItem, size)}
+}
+
+// Push 分配网关序号并记录消息，send 在锁内调用以保证与补发的顺序一致
+func (o *Outbox) Push(build func(seq int64) []byte, send func(payload []byte)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	payload := build(o.seq)
+	if payload == nil {
+		o.seq--
+		return
+	}
+	idx := (o.head + o.size) % len(o.items)
+	if o.size == len(o.items) {
+		o.head = (o.head + 1) % len(o.items)
+	} else {
+		o.size++
+	}
+	o.items[idx] = outboxItem{seq: o.seq, payload: payload}
+	send(payload)
+}
+
+// Replay 补发序号大于 last 的消息
+//
+// attach 在锁内先于补发调用，用于绑定新会话，complete 表示能否补齐缺口（缺口已被覆盖或 last 超前时为 false），
+// 返回 false 时放弃补发。之后的新消息会排在补发之后。
+func (o *Outbox) Replay(last int64, attach func(latest int64, complete bool) bool, send func(payload []byte)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	oldest := o.seq - int64(o.size) + 1
+	complete := last == o.seq || (last < o.seq && last+1 >= oldest)
+	if !attach(o.seq, complete) || !complete {
+		return
+	}
+	for i := int(last + 1 - oldest); i < o.size; i++ {
+		send(o.items[(o.head+i)%len(o.items)].payload)
+	}
+}
+
+// Seq 返回最近一条消息的网关序号
+func (o *Outbox) Seq() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.seq
+}
+
+// Replay 断线重连管理：每个用户一个 Outbox，全部会话断开后保留 ttl
+type Replay struct {
+	mu     sync.Mutex
+	size   int
+	ttl    time.Duration
+	outbox map[int64]*Outbox
+	tokens map[string]ResumeToken
+}
+
+func NewReplay(size int, ttl time.Duration) *Replay {
+	if size <= 0 {
+		size = defaultOutboxSize
+	}
+	if ttl <= 0 {
+		ttl = defaultResumeTTL
+	}
+	return &Replay{
+		size:   size,
+		ttl:    ttl,
+		outbox: make(map[int64]*Outbox),
+		tokens: make(map[string]ResumeToken),
+	}
+}
+
+// Issue 用户登录后签发重连凭证，并确保 Outbox 存在
+//
+// o 为 Take 返回的 Outbox，重连时沿用该 Outbox，不再按 uid 查找；新登录时为 nil。
+func (r *Replay) Issue(token string, rt ResumeToken, o *Outbox) *Outbox {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if o == nil {
+		o = r.outbox[rt.UID]
+	}
+	if o == nil {
+		o = newOutbox(r.size)
+	}
+	r.outbox[rt.UID] = o
+	o.expireAt = 0
+	if len(o.tokens) >= maxResumeTokens {
+		delete(r.tokens, o.tokens[0])
+		o.tokens = o.tokens[1:]
+	}
+	o.tokens = append(o.tokens, token)
+	r.tokens[token] = rt
+	return o
+}
+
+// Take 使用重连凭证，凭证只能使用一次
+//
+// 返回的 Outbox 标记为使用中，Sweep 不再清理，直到调用方 Issue 或 Release。
+func (r *Replay) Take(token string) (ResumeToken, *Outbox, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rt, ok := r.tokens[token]
+	if !ok {
+		return rt, nil, false
+	}
+	delete(r.tokens, token)
+	o, ok := r.outbox[rt.UID]
+	if !ok || (o.expireAt != 0 && o.expireAt <= time.Now().UnixNano()) {
+		return rt, nil, false
+	}
+	for i, t := range o.tokens {
+		if t == token {
+			o.tokens = append(o.tokens[:i], o.tokens[i+1:]...)
+			break
+		}
+	}
+	o.expireAt = 0
+	return rt, o, true
+}
+
+// Get 返回在线或等待重连用户的 Outbox
+func (r *Replay) Get(uid int64) (*Outbox, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.outbox[uid]
+	return o, ok
+}
+
+// Release 用户全部会话断开，ttl 后丢弃 Outbox 与重连凭证
+func (r *Replay) Release(uid int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if o, ok := r.outbox[uid]; ok {
+		o.expireAt = time.Now().Add(r.ttl).UnixNano()
+	}
+}
+
+// Sweep 清理过期的 Outbox 与重连凭证
+func (r *Replay) Sweep() {
+	now := time.Now().UnixNano()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for uid, o := range r.outbox {
+		if o.expireAt == 0 || o.expireAt > now {
+			continue
+		}
+		for _, t := range o.tokens {
+			delete(r.tokens, t)
+		}
+		delete(r.outbox, uid)
+	}
+}
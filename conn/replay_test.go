@@ -0,0 +1,105 @@
+package conn
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func pushN(o *Outbox, n int) {
+	for i := 0; i < n; i++ {
+		o.Push(func(seq int64) []byte {
+			return []byte(strconv.FormatInt(seq, 10))
+		}, func([]byte) {})
+	}
+}
+
+func replayed(t *testing.T, o *Outbox, last int64) ([]string, bool) {
+	t.Helper()
+	var got []string
+	var ok bool
+	o.Replay(last, func(_ int64, complete bool) bool {
+		ok = complete
+		return true
+	}, func(payload []byte) {
+		got = append(got, string(payload))
+	})
+	return got, ok
+}
+
+func TestOutboxReplay(t *testing.T) {
+	r := NewReplay(4, 0)
+	o := r.Issue("t1", ResumeToken{UID: 1}, nil)
+	pushN(o, 6) // 保留 3..6
+
+	got, ok := replayed(t, o, 4)
+	if !ok || len(got) != 2 || got[0] != "5" || got[1] != "6" {
+		t.Fatalf("replay from 4: got %v ok=%v", got, ok)
+	}
+	if got, ok = replayed(t, o, 2); !ok || len(got) != 4 || got[0] != "3" {
+		t.Fatalf("replay from 2: got %v ok=%v", got, ok)
+	}
+	if got, ok = replayed(t, o, 6); !ok || len(got) != 0 {
+		t.Fatalf("replay up to date: got %v ok=%v", got, ok)
+	}
+	// 缺口已被覆盖
+	if _, ok = replayed(t, o, 1); ok {
+		t.Fatal("expected gap too old")
+	}
+	// 客户端序号超前
+	if _, ok = replayed(t, o, 7); ok {
+		t.Fatal("expected invalid last seq")
+	}
+}
+
+func TestReplayTokens(t *testing.T) {
+	r := NewReplay(4, 0)
+	r.Issue("t1", ResumeToken{UID: 1, DeviceId: "phone"}, nil)
+
+	rt, o, ok := r.Take("t1")
+	if !ok || rt.UID != 1 || rt.DeviceId != "phone" || o == nil {
+		t.Fatalf("take token: %+v ok=%v", rt, ok)
+	}
+	if _, _, ok = r.Take("t1"); ok {
+		t.Fatal("token reused")
+	}
+
+	r.Issue("t2", ResumeToken{UID: 1}, nil)
+	r.ttl = 0
+	r.Release(1)
+	r.Sweep()
+	if _, _, ok = r.Take("t2"); ok {
+		t.Fatal("token survived expired outbox")
+	}
+	if _, ok = r.Get(1); ok {
+		t.Fatal("outbox survived sweep")
+	}
+}
+
+func TestReplayTakeSweep(t *testing.T) {
+	r := NewReplay(4, time.Millisecond)
+	o := r.Issue("t1", ResumeToken{UID: 1}, nil)
+	pushN(o, 3)
+	r.Release(1)
+
+	// 凭证使用后到签发新凭证前，Sweep 不清理使用中的 Outbox
+	_, taken, ok := r.Take("t1")
+	if !ok || taken != o {
+		t.Fatal("take token")
+	}
+	time.Sleep(2 * time.Millisecond)
+	r.Sweep()
+	if got := r.Issue("t2", ResumeToken{UID: 1}, taken); got != o || o.Seq() != 3 {
+		t.Fatalf("outbox replaced, seq %d", got.Seq())
+	}
+	if current, _ := r.Get(1); current != o {
+		t.Fatal("outbox not restored")
+	}
+
+	// 已过期但尚未清理的 Outbox 不能恢复
+	r.Release(1)
+	time.Sleep(2 * time.Millisecond)
+	if _, _, ok = r.Take("t2"); ok {
+		t.Fatal("expired outbox resumed")
+	}
+}
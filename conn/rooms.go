@@ -0,0 +1,101 @@
+package conn
+
+import "sync"
+
+// Rooms 房间成员，会话关闭后通过 Drop 移出全部房间
+type Rooms struct {
+	mu    sync.RWMutex
+	rooms map[string]map[*Client]struct{}
+}
+
+func NewRooms() *Rooms {
+	return &Rooms{rooms: make(map[string]map[*Client]struct{})}
+}
+
+// Join 加入房间，已在房间中或会话已关闭时返回 false
+func (r *Rooms) Join(c *Client, room string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.dropped {
+		return false
+	}
+	if _, ok := c.rooms[room]; ok {
+		return false
+	}
+	if c.rooms == nil {
+		c.rooms = make(map[string]struct{})
+	}
+	c.rooms[room] = struct{}{}
+	members := r.rooms[room]
+	if members == nil {
+		members = make(map[*Client]struct{})
+		r.rooms[room] = members
+	}
+	members[c] = struct{}{}
+	return true
+}
+
+// Leave 离开房间，不在房间中时返回 false
+func (r *Rooms) Leave(c *Client, room string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := c.rooms[room]; !ok {
+		return false
+	}
+	r.leave(c, room)
+	return true
+}
+
+func (r *Rooms) leave(c *Client, room string) {
+	delete(c.rooms, room)
+	members := r.rooms[room]
+	delete(members, c)
+	if len(members) == 0 {
+		delete(r.rooms, room)
+	}
+}
+
+// Clear 解散房间，移出全部成员
+func (r *Rooms) Clear(room string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.rooms[room] {
+		delete(c.rooms, room)
+	}
+	delete(r.rooms, room)
+}
+
+// Drop 会话关闭后移出全部房间，之后不能再加入
+func (r *Rooms) Drop(c *Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.dropped = true
+	for room := range c.rooms {
+		r.leave(c, room)
+	}
+}
+
+// Snapshot 返回房间全部成员的副本，遍历时不持有房间锁，发送期间不阻塞加入、离开与 Drop
+func (r *Rooms) Snapshot(room string) []*Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := make([]*Client, 0, len(r.rooms[room]))
+	for c := range r.rooms[room] {
+		members = append(members, c)
+	}
+	return members
+}
+
+// Count 返回房间的成员数
+func (r *Rooms) Count(room string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.rooms[room])
+}
+
+// Joined 返回会话加入的房间数
+func (r *Rooms) Joined(c *Client) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(c.rooms)
+}
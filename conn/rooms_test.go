@@ -0,0 +1,51 @@
+package conn
+
+import "testing"
+
+func TestRooms(t *testing.T) {
+	r := NewRooms()
+	a := NewClient(1, "a", "", nil)
+	b := NewClient(2, "b", "", nil)
+	if !r.Join(a, "wingo/t1") || r.Join(a, "wingo/t1") {
+		t.Fatal("join should be idempotent")
+	}
+	r.Join(b, "wingo/t1")
+	r.Join(a, "wingo/lobby")
+	if r.Count("wingo/t1") != 2 || r.Joined(a) != 2 {
+		t.Fatalf("count=%d joined=%d", r.Count("wingo/t1"), r.Joined(a))
+	}
+	if !r.Leave(b, "wingo/t1") || r.Leave(b, "wingo/t1") {
+		t.Fatal("leave should be idempotent")
+	}
+
+	r.Clear("wingo/t1")
+	if r.Count("wingo/t1") != 0 || r.Joined(a) != 1 {
+		t.Fatalf("room not cleared: count=%d joined=%d", r.Count("wingo/t1"), r.Joined(a))
+	}
+
+	// 会话关闭后移出全部房间，不能再加入
+	r.Drop(a)
+	if r.Joined(a) != 0 || len(r.rooms) != 0 {
+		t.Fatalf("session not dropped: %v", r.rooms)
+	}
+	if r.Join(a, "wingo/t1") {
+		t.Fatal("dropped session joined a room")
+	}
+}
+
+func TestRoomsSnapshot(t *testing.T) {
+	r := NewRooms()
+	a := NewClient(1, "a", "", nil)
+	b := NewClient(2, "b", "", nil)
+	r.Join(a, "wingo/t1")
+	r.Join(b, "wingo/t1")
+
+	// 遍历副本时可以修改房间，副本不受影响
+	snapshot := r.Snapshot("wingo/t1")
+	for _, c := range snapshot {
+		r.Drop(c)
+	}
+	if len(snapshot) != 2 || r.Count("wingo/t1") != 0 {
+		t.Fatalf("snapshot %d members, %d left", len(snapshot), r.Count("wingo/t1"))
+	}
+}
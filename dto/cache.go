@@ -4,8 +4,14 @@ import "fmt"
 
 const (
 	UserTokenKey = "system:user:token:%d"
+	// LinkNonceKey 游戏服务握手使用过的 nonce，防止重放
+	LinkNonceKey = "system:gateway:link:nonce:%s:%s"
 )
 
 func GetUserTokenKey(userId int64) string {
 	return fmt.Sprintf(UserTokenKey, userId)
 }
+
+func GetLinkNonceKey(alias, nonce string) string {
+	return fmt.Sprintf(LinkNonceKey, alias, nonce)
+}
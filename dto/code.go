@@ -0,0 +1,35 @@
+package dto
+
+// 系统频道错误码，数值保持稳定，客户端据此区分失败原因
+const (
+	CodeOK              = 0    // 成功
+	CodeBadRequest      = 1001 // 消息格式错误
+	CodeUnauthenticated = 1002 // 未认证或认证失败
+	CodeUnknownServer   = 1003 // 未知的游戏服务
+	CodeRateLimited     = 1004 // 请求过于频繁
+	CodeGameOffline     = 1005 // 游戏服务不在线
+	CodeLoginConflict   = 1006 // 已在其他设备登录
+	CodeResumeGap       = 1007 // 断线期间的消息已无法补发，需要全量同步
+	CodeRoomDenied      = 1008 // 房间不是公开房间或加入的房间过多
+	CodeQueueFull       = 1009 // 游戏服务的发送队列已满
+	CodeTimeout         = 1010 // 游戏服务未在超时时间内应答
+)
+
+var codeMsg = map[int]string{
+	CodeOK:              "ok",
+	CodeBadRequest:      "bad request",
+	CodeUnauthenticated: "unauthenticated",
+	CodeUnknownServer:   "unknown server",
+	CodeRateLimited:     "rate limited",
+	CodeGameOffline:     "game offline",
+	CodeLoginConflict:   "already logged in",
+	CodeResumeGap:       "resume gap too old",
+	CodeRoomDenied:      "room denied",
+	CodeQueueFull:       "game queue full",
+	CodeTimeout:         "game timeout",
+}
+
+// CodeMsg 返回错误码的默认描述
+func CodeMsg(code int) string {
+	return codeMsg[code]
+}
@@ -8,19 +8,73 @@ type CommonReq struct {
 	Seq    int64           `json:"seq"`              // 请求id
 	UserId int64           `json:"userId,omitempty"` // 用户id
 	Data   json.RawMessage `json:"data,omitempty"`   // 数据
+
+	UserIds []int64 `json:"-"` // 网关发给游戏服务的控制消息携带的用户列表
+	System  bool    `json:"-"` // 网关发给游戏服务的控制消息，Server 为目标游戏服务
+
+	Instance string `json:"-"` // 指定游戏服务实例，为空时按 UserId 选择实例
 }
 
 type AuthReq struct {
 	Token string `json:"token"`
 }
 
+// ResumeReq 断线重连请求
+type ResumeReq struct {
+	ResumeToken string `json:"resumeToken"` // 上次认证或重连时下发的重连凭证
+	LastSeq     int64  `json:"lastSeq"`     // 已收到的最大网关序号
+}
+
+// AuthRes 认证/重连结果
+type AuthRes struct {
+	UserId      int64  `json:"userId"`                // 绑定的用户id
+	SessionId   string `json:"sessionId"`             // 网关会话id
+	ResumeToken string `json:"resumeToken,omitempty"` // 重连凭证，只能使用一次
+	LastSeq     int64  `json:"lastSeq"`               // 当前最大网关序号
+}
+
 // CommonRes 给客户端的消息
 type CommonRes struct {
 	Server string          `json:"server"`           // 服务名称
 	Event  string          `json:"event"`            // 客户端事件
 	Seq    int64           `json:"seq,omitempty"`    // 请求id
 	UserId int64           `json:"userId,omitempty"` // 用户id，为 0 发给所有人
+	GSeq   int64           `json:"gseq,omitempty"`   // 网关序号，发给指定用户的消息按用户递增，用于断线重连补发
 	Code   int             `json:"code"`             // 错误码
 	Msg    string          `json:"msg,omitempty"`    // 错误信息
 	Data   json.RawMessage `json:"data,omitempty"`   // 数据
+	Room   string          `json:"room,omitempty"`   // 房间，非空时发给房间全部成员
+	Late   bool            `json:"late,omitempty"`   // 请求已超时后到达的应答，request.late 为 flag 时下发
+	System bool            `json:"-"`                // 游戏服务发给网关的控制消息，不下发给客户端
+
+	UserIds       []int64 `json:"-"` // 多播的目标用户，非空时忽略 UserId
+	ReportOffline bool    `json:"-"` // 多播后向游戏服务回报不在线的用户
+	Instance      string  `json:"-"` // 发出消息的游戏服务实例
+}
+
+// RoomReq 客户端加入/离开公开房间
+type RoomReq struct {
+	Server string `json:"server"` // 游戏服务别名
+	Room   string `json:"room"`   // 房间名
+}
+
+// AnnounceReq 管理接口发送全服公告
+type AnnounceReq struct {
+	Msg  string          `json:"msg"`  // 公告内容
+	Data json.RawMessage `json:"data"` // 附加数据，原样下发
+}
+
+// UserEvent 网关发给游戏服务的用户生命周期事件，userId 为事件的用户
+type UserEvent struct {
+	SessionId string `json:"sessionId"`          // 网关会话id
+	DeviceId  string `json:"deviceId,omitempty"` // 设备id
+	Server    string `json:"server,omitempty"`   // 会话绑定的游戏服务，user_online 时为空
+	Reason    string `json:"reason,omitempty"`   // user_unbind、user_offline 的原因
+	Sessions  int    `json:"sessions"`           // 事件发生后用户的在线会话数
+	Time      int64  `json:"time"`               // 毫秒时间戳
+}
+
+// KickedRes 会话被踢下线通知
+type KickedRes struct {
+	Reason string `json:"reason"` // 踢下线原因
 }
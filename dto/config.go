@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/aluka-7/utils"
+	"sync"
 )
 
 type WsConfig struct {
@@ -17,18 +18,175 @@ type TcpConfig struct {
 type Gateway struct {
 	Path   string
 	Config GatewayConfig
+
+	mu        sync.Mutex
+	listeners []func(cfg *GatewayConfig)
 }
 
 type GatewayConfig struct {
-	GameList []string `json:"gameList"`
+	GameList    []string          `json:"gameList"`
+	Jwt         JwtConfig         `json:"jwt"`
+	Login       LoginConfig       `json:"login"`
+	Resume      ResumeConfig      `json:"resume"`
+	RateLimit   RateLimitConfig   `json:"rateLimit"`
+	IpFilter    IpFilterConfig    `json:"ipFilter"`
+	Handshake   HandshakeConfig   `json:"handshake"`
+	Compression CompressionConfig `json:"compression"`
+	Heartbeat   HeartbeatConfig   `json:"heartbeat"`
+	Outbound    OutboundConfig    `json:"outbound"`
+	Rooms       RoomsConfig       `json:"rooms"`
+	Admin       AdminConfig       `json:"admin"`
+	Link        LinkConfig        `json:"link"`
+	Request     RequestConfig     `json:"request"`
+	Lifecycle   LifecycleConfig   `json:"lifecycle"`
+}
+
+// LifecycleConfig 用户生命周期事件配置
+type LifecycleConfig struct {
+	Subscribers []string `json:"subscribers"` // 接收 user_bind、user_unbind、user_offline 事件的游戏服务别名，只收到绑定过该服务的会话的事件
+	Presence    bool     `json:"presence"`    // 全部订阅的服务都收到每个会话的 user_online、user_offline，用于好友在线状态等大厅功能
+}
+
+// RequestConfig 客户端请求的应答超时配置，按 (userId, seq) 匹配游戏服务的应答
+type RequestConfig struct {
+	Timeout int            `json:"timeout"` // 游戏服务应答的超时，单位毫秒，0 表示不限制
+	Events  map[string]int `json:"events"`  // 按 "server/event" 覆盖 timeout，0 表示该事件不限制
+	Late    string         `json:"late"`    // 超时后到达的应答：discard（默认）丢弃，flag 标记 late 后下发
+}
+
+// LinkConfig 游戏服务 TCP 连接的握手与连接方向配置
+type LinkConfig struct {
+	Secret           string            `json:"secret"`           // 共享密钥，用于握手签名
+	Secrets          map[string]string `json:"secrets"`          // 游戏服务别名 -> 独立密钥，优先于 secret
+	MaxSkew          int               `json:"maxSkew"`          // 握手时间戳允许的误差，单位秒，默认 30
+	HandshakeTimeout int               `json:"handshakeTimeout"` // 连接后完成握手的时间，单位秒，默认 5
+
+	Mode       string              `json:"mode"`       // listen（默认）：游戏服务连接网关；connect：网关连接 endpoints；both：两者同时使用，只在启动时读取
+	Endpoints  map[string][]string `json:"endpoints"`  // 游戏服务别名 -> 网关主动连接的地址，connect/both 模式下生效
+	MinBackoff int                 `json:"minBackoff"` // 重连间隔的初始值，单位毫秒，默认 500
+	MaxBackoff int                 `json:"maxBackoff"` // 重连间隔的上限，单位毫秒，默认 30000
+
+	Grace    int `json:"grace"`    // 游戏服务全部实例断开后暂存客户端消息等待重连的时间，单位秒，0 表示不暂存
+	HoldSize int `json:"holdSize"` // 每个游戏服务最多暂存的消息数，默认 1024，不超过游戏服务会话的发送队列容量 1024
+
+	PingInterval int `json:"pingInterval"` // 网关发送链路心跳的间隔，单位秒，默认 10，不超过 pingTimeout 的一半
+	PingTimeout  int `json:"pingTimeout"`  // 超过该时间未收到游戏服务的 pong 时断开，单位秒，0 表示不发送链路心跳
+}
+
+// AdminConfig 管理接口与全服公告配置
+type AdminConfig struct {
+	Token      string   `json:"token"`      // 管理接口的 Bearer token，为空时关闭管理接口
+	Announcers []string `json:"announcers"` // 可以发送全服公告的游戏服务别名
+}
+
+// RoomsConfig 房间配置
+type RoomsConfig struct {
+	Public   map[string][]string `json:"public"`   // 游戏服务别名 -> 客户端可以自行加入的公开房间
+	MaxRooms int                 `json:"maxRooms"` // 每个会话最多自行加入的房间数，默认 16
+}
+
+// OutboundConfig 下行消息发送队列配置
+type OutboundConfig struct {
+	QueueSize   int    `json:"queueSize"`   // 每个会话最多排队的消息数，默认 256
+	Overflow    string `json:"overflow"`    // 队列已满时的策略：drop_oldest（默认）、drop_newest、disconnect
+	MaxBuffered int    `json:"maxBuffered"` // 连接写缓冲超过该字节数时暂停发送，消息留在队列中，默认 4MB
+}
+
+// HeartbeatConfig 心跳与超时配置，时间单位为秒
+type HeartbeatConfig struct {
+	Timeout      int  `json:"timeout"`      // 心跳超时，默认 30
+	AuthTimeout  int  `json:"authTimeout"`  // 建立连接后必须完成认证的时间，默认 5
+	IdleTimeout  int  `json:"idleTimeout"`  // 未发送业务消息（心跳除外）的最长时间，0 表示不限制
+	ServerPing   bool `json:"serverPing"`   // 服务端定时发送 websocket ping 帧，客户端回复 pong 即视为存活
+	PingInterval int  `json:"pingInterval"` // ping 间隔，默认 10，不超过心跳超时的一半
+}
+
+// CompressionConfig permessage-deflate 压缩配置
+type CompressionConfig struct {
+	Enable                bool `json:"enable"`
+	Level                 int  `json:"level"`                 // 压缩级别 1-9，默认 1（最快）
+	Threshold             int  `json:"threshold"`             // 下行消息达到该字节数才压缩，默认 256
+	ClientContextTakeover bool `json:"clientContextTakeover"` // 允许客户端跨消息复用压缩上下文，每个连接额外保留 32KB 窗口
+	MaxInflate            int  `json:"maxInflate"`            // 单条上行消息解压后的最大字节数，默认 1MB
+}
+
+// HandshakeConfig websocket 握手校验配置
+type HandshakeConfig struct {
+	Origins         []string `json:"origins"`         // 允许的 Origin，支持 *.example.com 通配子域名，为空时不校验
+	Protocols       []string `json:"protocols"`       // 允许的子协议，按客户端提供的顺序选取第一个
+	RequireProtocol bool     `json:"requireProtocol"` // 客户端必须提供 Protocols 中的子协议
+	CaptureHeaders  []string `json:"captureHeaders"`  // 保存到会话中的请求头，默认 User-Agent、X-Client-Version
+}
+
+// IpFilterConfig IP 访问控制配置
+type IpFilterConfig struct {
+	Allow         []string `json:"allow"`         // 白名单 CIDR，为空时不限制
+	Deny          []string `json:"deny"`          // 黑名单 CIDR，优先于白名单
+	MaxConnsPerIp int      `json:"maxConnsPerIp"` // 单 IP 最大在线连接数，0 表示不限制
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Conn          Limit            `json:"conn"`          // 单连接上行消息
+	User          Limit            `json:"user"`          // 单用户上行消息，多个会话共享
+	Events        map[string]Limit `json:"events"`        // 按 "server/event" 覆盖单连接限制
+	Ip            Limit            `json:"ip"`            // 单 IP 新建连接
+	MaxViolations int              `json:"maxViolations"` // 10 秒内超限次数达到该值后关闭连接，0 表示不关闭
+}
+
+// Limit 令牌桶参数，Rate 为 0 表示不限制
+type Limit struct {
+	Rate  float64 `json:"rate"`  // 每秒令牌数
+	Burst int     `json:"burst"` // 桶容量
+}
+
+// ResumeConfig 断线重连配置
+type ResumeConfig struct {
+	Size int `json:"size"` // 每个用户保留的最近下行消息数，默认 128
+	Ttl  int `json:"ttl"`  // 全部会话断开后保留的秒数，默认 60
+}
+
+// LoginConfig 多端登录配置
+type LoginConfig struct {
+	Policy      string `json:"policy"`      // kick(默认)/reject/multi
+	MaxSessions int    `json:"maxSessions"` // multi 策略下每个用户最多同时在线的会话数，按 token 中的设备id区分
+}
+
+// JwtConfig jwt 校验配置
+type JwtConfig struct {
+	Keys     []JwtKey `json:"keys"`     // 校验密钥，按 token header 中的 kid 选择
+	JwksFile string   `json:"jwksFile"` // 本地 JWKS 文件，可选
+}
+
+// JwtKey jwt 校验密钥
+type JwtKey struct {
+	Kid       string `json:"kid"`       // 密钥 id
+	Alg       string `json:"alg"`       // 签名算法 HS256/RS256/ES256 等，为空时按密钥类型推断
+	Secret    string `json:"secret"`    // HMAC 密钥
+	PublicKey string `json:"publicKey"` // RSA/ECDSA PEM 公钥
+}
+
+// OnChanged 注册配置变更回调，配置中心推送新配置后依次调用
+func (b *Gateway) OnChanged(fn func(cfg *GatewayConfig)) {
+	b.mu.Lock()
+	b.listeners = append(b.listeners, fn)
+	b.mu.Unlock()
 }
 
 func (b *Gateway) Changed(data map[string]string) {
 	if v, ok := data[b.Path]; ok {
-		err := json.Unmarshal(utils.Str2Bytes(v), &b.Config)
+		var cfg GatewayConfig
+		err := json.Unmarshal(utils.Str2Bytes(v), &cfg)
 		if err != nil {
 			return
 		}
+		b.mu.Lock()
+		b.Config = cfg
+		listeners := b.listeners
+		b.mu.Unlock()
+		for _, fn := range listeners {
+			fn(&b.Config)
+		}
 	} else {
 		panic(fmt.Sprintf("配置中心不存在[%s]配置", b.Path))
 	}
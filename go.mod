@@ -8,6 +8,7 @@ require (
 	github.com/aluka-7/configuration v1.0.3
 	github.com/aluka-7/utils v1.0.8
 	github.com/aluka-7/web v1.1.2
+	github.com/gobwas/httphead v0.1.0
 	github.com/gobwas/ws v1.3.1
 	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/golang/protobuf v1.5.2
@@ -33,7 +34,6 @@ require (
 	github.com/go-playground/universal-translator v0.18.0 // indirect
 	github.com/go-playground/validator/v10 v10.11.0 // indirect
 	github.com/go-redis/redis/v8 v8.11.5 // indirect
-	github.com/gobwas/httphead v0.1.0 // indirect
 	github.com/gobwas/pool v0.2.1 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
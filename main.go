@@ -29,9 +29,12 @@ func App(conf configuration.Configuration) {
 	var gateway = &dto.Gateway{Path: fmt.Sprintf("/system/app/game/gateway")}
 	conf.Get("app", "game", "", []string{"gateway"}, gateway)
 
-	wss := wire.InitializeWsServer(&gateway.Config, ce, tc.Addr)
+	wss := wire.InitializeWsServer(gateway, ce, tc.Addr)
 
 	web.App(func(eng *echo.Echo) {
+		// 管理接口
+		wss.RegisterAdmin(eng)
+
 		// Start serving!
 		go func() {
 			err := gnet.Run(
@@ -13,15 +13,21 @@ import (
 const (
 	frameHeaderLen = 4
 	maxFrameSize   = 4 * 1024 * 1024
+	// serverSystem 游戏服务发给网关的控制消息使用的服务名，与 ws.ServerSystem 一致
+	serverSystem = "system"
 )
 
 func EncodeReq(msg *dto.CommonReq) ([]byte, error) {
 	packet := &pb.TcpMessage{
-		Server: msg.Server,
-		Event:  msg.Event,
-		Seq:    msg.Seq,
-		UserId: msg.UserId,
-		Data:   msg.Data,
+		Server:  msg.Server,
+		Event:   msg.Event,
+		Seq:     msg.Seq,
+		UserId:  msg.UserId,
+		Data:    msg.Data,
+		UserIds: msg.UserIds,
+	}
+	if msg.System {
+		packet.Server = serverSystem
 	}
 	body, err := proto.Marshal(packet)
 	if err != nil {
@@ -43,6 +49,11 @@ func DecodeRes(payload []byte, alias string) (*dto.CommonRes, error) {
 		Code:   int(packet.Code),
 		Msg:    packet.Msg,
 		Data:   packet.Data,
+		Room:   packet.Room,
+		System: packet.Server == serverSystem,
+
+		UserIds:       packet.UserIds,
+		ReportOffline: packet.ReportOffline,
 	}
 	return res, nil
 }
@@ -0,0 +1,192 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aluka-7/game-gateway/utils/logger"
+)
+
+const (
+	// LinkModeListen 游戏服务连接网关
+	LinkModeListen = "listen"
+	// LinkModeConnect 网关连接 link.endpoints 中的游戏服务，不监听端口
+	LinkModeConnect = "connect"
+	// LinkModeBoth 同时监听端口与连接 endpoints
+	LinkModeBoth = "both"
+
+	defaultMinBackoff  = 500 * time.Millisecond
+	defaultMaxBackoff  = 30 * time.Second
+	defaultDialTimeout = 5 * time.Second
+)
+
+// 主动连接的状态
+const (
+	LinkConnecting = "connecting" // 正在建立连接或握手
+	LinkConnected  = "connected"  // 握手完成，正在收发消息
+	LinkBackoff    = "backoff"    // 连接失败或断开，等待重连
+)
+
+var errLinkClosed = errors.New("link closed")
+
+// EndpointState 主动连接的 endpoint 状态
+type EndpointState struct {
+	Alias     string    `json:"alias"`
+	Addr      string    `json:"addr"`
+	State     string    `json:"state"`
+	Instance  string    `json:"instance,omitempty"`  // 握手上报的实例id，连接成功后保留到下次握手
+	Failures  int       `json:"failures"`            // 连续失败次数，握手成功后清零
+	LastError string    `json:"lastError,omitempty"` // 最近一次失败或断开的原因
+	Since     time.Time `json:"since"`               // 进入当前状态的时间
+	Retry     time.Time `json:"retry,omitzero"`      // backoff 状态下的下次重连时间
+}
+
+// connector 网关到一个 endpoint 的连接，断开后按指数退避重连
+type connector struct {
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	state EndpointState
+}
+
+func (c *connector) set(fn func(s *EndpointState)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.state)
+}
+
+func (c *connector) snapshot() EndpointState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func endpointKey(alias, addr string) string {
+	return alias + "/" + addr
+}
+
+// startConnectors 按启动时的 link.mode 开始连接 endpoints
+func (ts *TcpServer) startConnectors() {
+	ts.connMu.Lock()
+	ts.running = true
+	ts.mode = ts.link.Load().mode
+	ts.connMu.Unlock()
+	ts.syncConnectors()
+}
+
+// syncConnectors 按当前 endpoints 增减连接，未变化的连接不受影响
+func (ts *TcpServer) syncConnectors() {
+	auth := ts.link.Load()
+	ts.connMu.Lock()
+	defer ts.connMu.Unlock()
+	if !ts.running || ts.closed.Load() {
+		return
+	}
+	want := make(map[string]struct{})
+	if ts.mode != LinkModeListen {
+		for alias, addrs := range auth.endpoints {
+			for _, addr := range addrs {
+				key := endpointKey(alias, addr)
+				want[key] = struct{}{}
+				if _, ok := ts.connectors[key]; ok {
+					continue
+				}
+				ctx, cancel := context.WithCancel(ts.ctx)
+				c := &connector{cancel: cancel, state: EndpointState{Alias: alias, Addr: addr}}
+				ts.connectors[key] = c
+				go ts.connect(ctx, c, alias, addr)
+			}
+		}
+	}
+	for key, c := range ts.connectors {
+		if _, ok := want[key]; !ok {
+			c.cancel()
+			delete(ts.connectors, key)
+		}
+	}
+}
+
+// EndpointStates 全部主动连接的状态，按别名与地址排序
+func (ts *TcpServer) EndpointStates() []EndpointState {
+	ts.connMu.Lock()
+	states := make([]EndpointState, 0, len(ts.connectors))
+	for _, c := range ts.connectors {
+		states = append(states, c.snapshot())
+	}
+	ts.connMu.Unlock()
+	sort.Slice(states, func(i, j int) bool {
+		return endpointKey(states[i].Alias, states[i].Addr) < endpointKey(states[j].Alias, states[j].Addr)
+	})
+	return states
+}
+
+// connect 连接 endpoint 直到 ctx 取消，连接建立后与游戏服务连接网关的处理相同
+func (ts *TcpServer) connect(ctx context.Context, c *connector, alias, addr string) {
+	failures := 0
+	for {
+		c.set(func(s *EndpointState) {
+			s.State, s.Since, s.Retry = LinkConnecting, time.Now(), time.Time{}
+		})
+		err := ts.dial(ctx, c, alias, addr)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errLinkClosed) {
+			failures = 0
+		} else {
+			failures++
+			logger.Log.Warnf("TcpServer connect game server %s at %s failed %d times: %v", alias, addr, failures, err)
+		}
+		delay := backoff(ts.link.Load(), failures)
+		c.set(func(s *EndpointState) {
+			s.State, s.Since, s.Retry = LinkBackoff, time.Now(), time.Now().Add(delay)
+			s.Failures, s.LastError = failures, err.Error()
+		})
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// dial 建立连接并完成握手，连接断开后返回 errLinkClosed
+func (ts *TcpServer) dial(ctx context.Context, c *connector, alias, addr string) error {
+	d := net.Dialer{Timeout: defaultDialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// endpoint 被移除或网关关闭时断开连接
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	h, reader, err := ts.accept(conn, alias)
+	if err != nil {
+		return err
+	}
+	c.set(func(s *EndpointState) {
+		s.State, s.Since, s.Instance = LinkConnected, time.Now(), h.InstanceId
+		s.Failures, s.LastError = 0, ""
+	})
+	ts.handleRequest(h.Alias, h.InstanceId, conn, reader)
+	logger.Log.Warnf("TcpServer link to game server %s instance %s at %s closed", alias, h.InstanceId, addr)
+	return errLinkClosed
+}
+
+// backoff 第 n 次连续失败后的重连间隔，在 [d/2, d] 内随机，避免多个网关同时重连
+func backoff(auth *linkAuth, failures int) time.Duration {
+	d := auth.minBackoff
+	for i := 1; i < failures && d < auth.maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, auth.maxBackoff)
+	return d/2 + rand.N(d/2+1)
+}
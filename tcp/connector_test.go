@@ -0,0 +1,123 @@
+package tcp
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+)
+
+func TestBackoff(t *testing.T) {
+	auth := newLinkAuth(dto.LinkConfig{MinBackoff: 100, MaxBackoff: 1000})
+	for failures, want := range []time.Duration{100, 100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+		for i := 0; i < 100; i++ {
+			if d := backoff(auth, failures); d < want/2 || d > want {
+				t.Fatalf("failures %d: %v not in [%v, %v]", failures, d, want/2, want)
+			}
+		}
+	}
+}
+
+// gameEndpoint 模拟等待网关连接的游戏服务，每个连接都以 alias 握手
+func gameEndpoint(t *testing.T, alias string) (string, <-chan net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			frame, _ := EncodeHandshake(NewHandshake(alias, alias+"-1", "shared"))
+			_, _ = conn.Write(frame)
+			if ReadHandshakeReply(bufio.NewReader(conn)) != nil {
+				_ = conn.Close()
+				continue
+			}
+			conns <- conn
+		}
+	}()
+	return l.Addr().String(), conns
+}
+
+func waitEndpoint(t *testing.T, ts *TcpServer, check func(s EndpointState) bool) EndpointState {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		states := ts.EndpointStates()
+		if len(states) == 1 && check(states[0]) {
+			return states[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected endpoint states %+v", states)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnector(t *testing.T) {
+	addr, conns := gameEndpoint(t, "wingo")
+	link := dto.LinkConfig{Secret: "shared", Mode: LinkModeConnect, Endpoints: map[string][]string{"wingo": {addr}}, MinBackoff: 10, MaxBackoff: 50}
+	ts := newTestServer(nil, link)
+	defer ts.Stop()
+
+	// Run 之前不连接
+	ts.SetLink(link)
+	if len(ts.EndpointStates()) != 0 {
+		t.Fatal("connected before Run")
+	}
+	ts.startConnectors()
+	s := waitEndpoint(t, ts, func(s EndpointState) bool { return s.State == LinkConnected })
+	if s.Alias != "wingo" || s.Addr != addr || s.Instance != "wingo-1" || !ts.Online("wingo") {
+		t.Fatalf("unexpected state %+v", s)
+	}
+
+	// 游戏服务断开后重连
+	_ = (<-conns).Close()
+	select {
+	case <-conns:
+	case <-time.After(2 * time.Second):
+		t.Fatal("not reconnected")
+	}
+	waitEndpoint(t, ts, func(s EndpointState) bool { return s.State == LinkConnected && s.Failures == 0 })
+
+	// 移除 endpoint 后断开连接
+	link.Endpoints = nil
+	ts.SetLink(link)
+	if len(ts.EndpointStates()) != 0 {
+		t.Fatal("endpoint not removed")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for ts.Online("wingo") {
+		if time.Now().After(deadline) {
+			t.Fatal("link not closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnectorFailures(t *testing.T) {
+	// endpoint 以其他别名握手时被拒绝
+	addr, _ := gameEndpoint(t, "crash")
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	refused := l.Addr().String()
+	_ = l.Close()
+
+	for _, addr := range []string{addr, refused} {
+		ts := newTestServer(nil, dto.LinkConfig{Secret: "shared", Mode: LinkModeBoth, Endpoints: map[string][]string{"wingo": {addr}}, MinBackoff: 10, MaxBackoff: 20})
+		ts.startConnectors()
+		s := waitEndpoint(t, ts, func(s EndpointState) bool { return s.Failures >= 2 })
+		if s.LastError == "" || ts.Online("wingo") || ts.Online("crash") {
+			t.Fatalf("%s: unexpected state %+v", addr, s)
+		}
+		ts.Stop()
+	}
+}
@@ -0,0 +1,236 @@
+package tcp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/aluka-7/game-gateway/dto"
+	pb "github.com/aluka-7/game-gateway/tcp/proto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	// EventHandshake 游戏服务连接后发送的第一帧及网关的应答
+	EventHandshake = "handshake"
+
+	defaultMaxSkew          = 30 * time.Second
+	defaultHandshakeTimeout = 5 * time.Second
+)
+
+var (
+	ErrHandshakeFormat    = errors.New("invalid handshake")
+	ErrHandshakeAlias     = errors.New("unknown game alias")
+	ErrHandshakeSecret    = errors.New("no secret configured for game alias")
+	ErrHandshakeExpired   = errors.New("handshake timestamp out of range")
+	ErrHandshakeSignature = errors.New("invalid handshake signature")
+	ErrHandshakeReplay    = errors.New("handshake nonce already used")
+)
+
+// Handshake 游戏服务握手信息，sign 为 HMAC-SHA256(secret, alias\ninstanceId\ntimestamp\nnonce) 的十六进制
+type Handshake struct {
+	Alias      string `json:"alias"`      // 游戏服务别名
+	InstanceId string `json:"instanceId"` // 实例id，同一别名的多个实例互相区分
+	Timestamp  int64  `json:"timestamp"`  // 秒级时间戳
+	Nonce      string `json:"nonce"`      // 随机串，有效期内只能使用一次
+	Sign       string `json:"sign"`
+}
+
+// NewHandshake 生成带签名的握手信息，供游戏服务使用
+func NewHandshake(alias, instanceId, secret string) *Handshake {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	h := &Handshake{
+		Alias:      alias,
+		InstanceId: instanceId,
+		Timestamp:  time.Now().Unix(),
+		Nonce:      hex.EncodeToString(b),
+	}
+	h.Sign = h.Signature(secret)
+	return h
+}
+
+// Signature 计算握手签名
+func (h *Handshake) Signature(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(h.Alias + "\n" + h.InstanceId + "\n" + strconv.FormatInt(h.Timestamp, 10) + "\n" + h.Nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EncodeHandshake 编码握手帧
+func EncodeHandshake(h *Handshake) ([]byte, error) {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSystem(&pb.TcpMessage{Event: EventHandshake, Data: data})
+}
+
+// ReadHandshakeReply 读取网关的握手应答，被拒绝时返回应答中的原因
+func ReadHandshakeReply(r io.Reader) error {
+	payload, err := ReadFrame(r)
+	if err != nil {
+		return err
+	}
+	packet := new(pb.TcpMessage)
+	if err = proto.Unmarshal(payload, packet); err != nil {
+		return err
+	}
+	if packet.Server != serverSystem || packet.Event != EventHandshake {
+		return ErrHandshakeFormat
+	}
+	if packet.Code != dto.CodeOK {
+		return errors.New(packet.Msg)
+	}
+	return nil
+}
+
+// encodeSystem 编码网关与游戏服务之间的控制消息
+func encodeSystem(packet *pb.TcpMessage) ([]byte, error) {
+	packet.Server = serverSystem
+	body, err := proto.Marshal(packet)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeFrame(body), nil
+}
+
+// linkAuth 握手校验与主动连接配置，配置变更后对新连接生效
+type linkAuth struct {
+	secret  string
+	secrets map[string]string
+	maxSkew time.Duration
+	timeout time.Duration
+
+	mode       string
+	endpoints  map[string][]string
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	grace      time.Duration
+	holdSize   int
+
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+}
+
+func newLinkAuth(cfg dto.LinkConfig) *linkAuth {
+	a := &linkAuth{
+		secret:     cfg.Secret,
+		secrets:    cfg.Secrets,
+		maxSkew:    time.Duration(cfg.MaxSkew) * time.Second,
+		timeout:    time.Duration(cfg.HandshakeTimeout) * time.Second,
+		mode:       cfg.Mode,
+		endpoints:  cfg.Endpoints,
+		minBackoff: time.Duration(cfg.MinBackoff) * time.Millisecond,
+		maxBackoff: time.Duration(cfg.MaxBackoff) * time.Millisecond,
+		grace:      time.Duration(cfg.Grace) * time.Second,
+		holdSize:   cfg.HoldSize,
+
+		pingInterval: time.Duration(cfg.PingInterval) * time.Second,
+		pingTimeout:  time.Duration(cfg.PingTimeout) * time.Second,
+	}
+	if a.maxSkew <= 0 {
+		a.maxSkew = defaultMaxSkew
+	}
+	if a.timeout <= 0 {
+		a.timeout = defaultHandshakeTimeout
+	}
+	switch a.mode {
+	case LinkModeListen, LinkModeConnect, LinkModeBoth:
+	default:
+		if a.mode != "" {
+			logger.Log.Errorf("unknown link mode %q, use %q", a.mode, LinkModeListen)
+		}
+		a.mode = LinkModeListen
+	}
+	if a.minBackoff <= 0 {
+		a.minBackoff = defaultMinBackoff
+	}
+	if a.maxBackoff < a.minBackoff {
+		a.maxBackoff = max(defaultMaxBackoff, a.minBackoff)
+	}
+	if a.holdSize <= 0 {
+		a.holdSize = defaultHoldSize
+	}
+	// 暂存的消息在重连时一次性放入新会话的发送队列，不能超过队列容量
+	if a.holdSize > defaultSendBufSize {
+		logger.Log.Warnf("link holdSize %d exceeds the session queue capacity, use %d", a.holdSize, defaultSendBufSize)
+		a.holdSize = defaultSendBufSize
+	}
+	// pingTimeout 为 0 时不发送链路心跳
+	if a.pingTimeout < 0 {
+		a.pingTimeout = 0
+	}
+	if a.pingInterval <= 0 {
+		a.pingInterval = defaultPingInterval
+	}
+	if a.pingTimeout > 0 {
+		// 超时前至少发送两次 ping
+		a.pingInterval = min(a.pingInterval, a.pingTimeout/2)
+	}
+	return a
+}
+
+func (a *linkAuth) secretFor(alias string) string {
+	if s, ok := a.secrets[alias]; ok {
+		return s
+	}
+	return a.secret
+}
+
+// handshake 读取并校验握手帧，返回校验通过的握手信息，alias 非空时只接受该别名
+func (ts *TcpServer) handshake(r io.Reader, alias string) (*Handshake, error) {
+	payload, err := ReadFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	packet := new(pb.TcpMessage)
+	if err = proto.Unmarshal(payload, packet); err != nil || packet.Server != serverSystem || packet.Event != EventHandshake {
+		return nil, ErrHandshakeFormat
+	}
+	var h Handshake
+	if err = json.Unmarshal(packet.Data, &h); err != nil || h.Alias == "" || h.InstanceId == "" || h.Nonce == "" {
+		return nil, ErrHandshakeFormat
+	}
+	if !ts.IsAllowedGame(h.Alias) || (alias != "" && h.Alias != alias) {
+		return &h, ErrHandshakeAlias
+	}
+	auth := ts.link.Load()
+	secret := auth.secretFor(h.Alias)
+	if secret == "" {
+		return &h, ErrHandshakeSecret
+	}
+	if skew := time.Since(time.Unix(h.Timestamp, 0)); skew > auth.maxSkew || skew < -auth.maxSkew {
+		return &h, ErrHandshakeExpired
+	}
+	if !hmac.Equal([]byte(h.Sign), []byte(h.Signature(secret))) {
+		return &h, ErrHandshakeSignature
+	}
+	// nonce 在时间戳有效期内保留，之后的重放会因时间戳过期被拒绝
+	if !ts.claimNonce(dto.GetLinkNonceKey(h.Alias, h.Nonce), 2*auth.maxSkew) {
+		return &h, ErrHandshakeReplay
+	}
+	return &h, nil
+}
+
+// handshakeReply 握手应答帧，err 为 nil 时表示接受
+func handshakeReply(err error) []byte {
+	packet := &pb.TcpMessage{Event: EventHandshake, Code: dto.CodeOK, Msg: dto.CodeMsg(dto.CodeOK)}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrHandshakeFormat):
+		packet.Code, packet.Msg = dto.CodeBadRequest, err.Error()
+	case errors.Is(err, ErrHandshakeAlias):
+		packet.Code, packet.Msg = dto.CodeUnknownServer, err.Error()
+	default:
+		packet.Code, packet.Msg = dto.CodeUnauthenticated, err.Error()
+	}
+	frame, _ := encodeSystem(packet)
+	return frame
+}
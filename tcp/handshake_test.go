@@ -0,0 +1,124 @@
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+)
+
+func newTestServer(gameList []string, link dto.LinkConfig) *TcpServer {
+	ts := NewTcpServer("", nil, gameList, link, nil, nil)
+	var mu sync.Mutex
+	nonces := make(map[string]bool)
+	ts.claimNonce = func(key string, _ time.Duration) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if nonces[key] {
+			return false
+		}
+		nonces[key] = true
+		return true
+	}
+	return ts
+}
+
+func handshakeFrame(t *testing.T, h *Handshake) *bytes.Reader {
+	t.Helper()
+	frame, err := EncodeHandshake(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(frame)
+}
+
+func TestHandshake(t *testing.T) {
+	ts := newTestServer([]string{"wingo", "crash"}, dto.LinkConfig{Secret: "shared", Secrets: map[string]string{"crash": "crash-secret"}})
+
+	h := NewHandshake("wingo", "wingo-1", "shared")
+	got, err := ts.handshake(handshakeFrame(t, h), "")
+	if err != nil || got.Alias != "wingo" || got.InstanceId != "wingo-1" {
+		t.Fatalf("valid handshake: %+v %v", got, err)
+	}
+	if _, err = ts.handshake(handshakeFrame(t, h), ""); !errors.Is(err, ErrHandshakeReplay) {
+		t.Fatalf("replayed nonce: %v", err)
+	}
+
+	old := NewHandshake("wingo", "wingo-1", "shared")
+	old.Timestamp -= 60
+	old.Sign = old.Signature("shared")
+	cases := []struct {
+		name string
+		h    *Handshake
+		want error
+	}{
+		{"wrong secret", NewHandshake("wingo", "wingo-1", "guess"), ErrHandshakeSignature},
+		{"alias secret", NewHandshake("crash", "crash-1", "shared"), ErrHandshakeSignature},
+		{"unknown alias", NewHandshake("poker", "poker-1", "shared"), ErrHandshakeAlias},
+		{"expired", old, ErrHandshakeExpired},
+		{"missing instance", NewHandshake("wingo", "", "shared"), ErrHandshakeFormat},
+	}
+	for _, c := range cases {
+		if _, err = ts.handshake(handshakeFrame(t, c.h), ""); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+	if _, err = ts.handshake(handshakeFrame(t, NewHandshake("crash", "crash-1", "crash-secret")), ""); err != nil {
+		t.Fatalf("alias secret: %v", err)
+	}
+
+	// 未配置密钥时拒绝全部游戏服务
+	ts.SetLink(dto.LinkConfig{})
+	if _, err = ts.handshake(handshakeFrame(t, NewHandshake("wingo", "wingo-1", "")), ""); !errors.Is(err, ErrHandshakeSecret) {
+		t.Fatalf("no secret: %v", err)
+	}
+
+	// 旧版本的首包 alias\n 不再被接受
+	if _, err = ts.handshake(bytes.NewReader([]byte("wingo\n")), ""); err == nil {
+		t.Fatal("plain alias accepted")
+	}
+}
+
+func TestServeRejects(t *testing.T) {
+	ts := newTestServer(nil, dto.LinkConfig{Secret: "shared"})
+
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		ts.serve(server)
+		close(done)
+	}()
+	frame, _ := EncodeHandshake(NewHandshake("wingo", "wingo-1", "guess"))
+	go func() { _, _ = client.Write(frame) }()
+	err := ReadHandshakeReply(bufio.NewReader(client))
+	if err == nil || err.Error() != ErrHandshakeSignature.Error() {
+		t.Fatalf("reject reply: %v", err)
+	}
+	<-done
+	if ts.Online("wingo") {
+		t.Fatal("rejected game server registered")
+	}
+}
+
+func TestServeTimeout(t *testing.T) {
+	ts := newTestServer(nil, dto.LinkConfig{Secret: "shared"})
+	ts.link.Load().timeout = 50 * time.Millisecond
+
+	server, client := net.Pipe()
+	defer client.Close()
+	done := make(chan struct{})
+	go func() {
+		ts.serve(server)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("idle connection not closed")
+	}
+}
@@ -0,0 +1,112 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	pb "github.com/aluka-7/game-gateway/tcp/proto"
+)
+
+const (
+	// EventPing 链路心跳，收到后回复 EventPong，seq 原样返回
+	EventPing = "ping"
+	// EventPong 链路心跳应答
+	EventPong = "pong"
+
+	defaultPingInterval = 10 * time.Second
+)
+
+// ErrHeartbeatTimeout 游戏服务未在超时时间内回复 pong
+var ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+
+// SessionState 游戏服务会话状态
+type SessionState struct {
+	Alias        string    `json:"alias"`
+	Instance     string    `json:"instance"`
+	Remote       string    `json:"remote"`
+	Since        time.Time `json:"since"`        // 注册时间
+	HeartbeatAge int64     `json:"heartbeatAge"` // 距最近一次收到 pong 的毫秒数，尚未收到时从注册时间算起
+}
+
+// heartbeatAge 距最近一次收到 pong 的时间
+func (gs *gameSession) heartbeatAge() time.Duration {
+	return time.Since(time.Unix(0, gs.lastPong.Load()))
+}
+
+// keepalive 定时发送 ping，超时未收到 pong 时关闭会话，直到会话关闭
+//
+// 只有 pong 计入心跳，游戏服务停止读取时即使仍在发送消息也会被发现。
+// link.pingTimeout 为 0 时不发送 ping，热更新开启后从开启时刻开始计算超时。
+func (ts *TcpServer) keepalive(session *gameSession) {
+	armed := true
+	for {
+		auth := ts.link.Load()
+		timer := time.NewTimer(auth.pingInterval)
+		select {
+		case <-session.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		auth = ts.link.Load()
+		if auth.pingTimeout <= 0 {
+			armed = false
+			continue
+		}
+		if !armed {
+			armed = true
+			session.lastPong.Store(time.Now().UnixNano())
+		}
+		if age := session.heartbeatAge(); age > auth.pingTimeout {
+			session.fail(fmt.Errorf("%w: no pong for %v", ErrHeartbeatTimeout, age.Round(time.Millisecond)))
+			return
+		}
+		frame, _ := encodeSystem(&pb.TcpMessage{Event: EventPing, Seq: time.Now().UnixMilli()})
+		// 队列已满时不重试，由超时处理
+		_ = session.push(frame)
+	}
+}
+
+// heartbeat 处理游戏服务发来的心跳帧，返回 false 表示不是心跳帧
+func (ts *TcpServer) heartbeat(session *gameSession, res *dto.CommonRes) bool {
+	if !res.System {
+		return false
+	}
+	switch res.Event {
+	case EventPing:
+		frame, _ := encodeSystem(&pb.TcpMessage{Event: EventPong, Seq: res.Seq})
+		_ = session.push(frame)
+	case EventPong:
+		session.lastPong.Store(time.Now().UnixNano())
+	default:
+		return false
+	}
+	return true
+}
+
+// Sessions 全部在线的游戏服务会话，按别名与实例id排序
+func (ts *TcpServer) Sessions() []SessionState {
+	var states []SessionState
+	ts.gameConn.Range(func(_, value any) bool {
+		for _, s := range value.(*gameGroup).all() {
+			states = append(states, SessionState{
+				Alias:        s.alias,
+				Instance:     s.instance,
+				Remote:       s.conn.RemoteAddr().String(),
+				Since:        s.since,
+				HeartbeatAge: s.heartbeatAge().Milliseconds(),
+			})
+		}
+		return true
+	})
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Alias != states[j].Alias {
+			return states[i].Alias < states[j].Alias
+		}
+		return states[i].Instance < states[j].Instance
+	})
+	return states
+}
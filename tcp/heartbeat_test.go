@@ -0,0 +1,123 @@
+package tcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	pb "github.com/aluka-7/game-gateway/tcp/proto"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestLinkHeartbeat(t *testing.T) {
+	outMsg := make(chan *dto.CommonRes, 4)
+	ts := newTestServer(nil, dto.LinkConfig{Secret: "shared"})
+	ts.outMsg = outMsg
+	auth := ts.link.Load()
+	auth.pingInterval, auth.pingTimeout = 20*time.Millisecond, 200*time.Millisecond
+	defer ts.Stop()
+
+	c, r := connectInstance(t, ts, "wingo-1")
+	waitInstances(t, ts, 1)
+	write := func(event string, seq int64) {
+		frame, _ := encodeSystem(&pb.TcpMessage{Event: event, Seq: seq})
+		_ = c.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := c.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() *pb.TcpMessage {
+		t.Helper()
+		_ = c.SetReadDeadline(time.Now().Add(time.Second))
+		payload, err := ReadFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := new(pb.TcpMessage)
+		if err = proto.Unmarshal(payload, packet); err != nil {
+			t.Fatal(err)
+		}
+		return packet
+	}
+
+	// 回复 pong 的游戏服务保持连接
+	for i := 0; i < 10; i++ {
+		ping := read()
+		if ping.Server != serverSystem || ping.Event != EventPing {
+			t.Fatalf("unexpected frame %+v", ping)
+		}
+		write(EventPong, ping.Seq)
+	}
+	states := ts.Sessions()
+	if len(states) != 1 || states[0].Instance != "wingo-1" || states[0].HeartbeatAge > 100 {
+		t.Fatalf("unexpected sessions %+v", states)
+	}
+
+	// 游戏服务发来的 ping 按 seq 回复 pong
+	write(EventPing, 42)
+	for {
+		if packet := read(); packet.Event == EventPong {
+			if packet.Seq != 42 {
+				t.Fatalf("pong seq %d", packet.Seq)
+			}
+			break
+		}
+	}
+	if len(outMsg) != 0 {
+		t.Fatal("heartbeat forwarded to clients")
+	}
+
+	// 停止回复 pong 后断开
+	go func() {
+		for {
+			if _, err := ReadFrame(r); err != nil {
+				return
+			}
+		}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for ts.Instances("wingo") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stale session not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLinkHeartbeatDisabled(t *testing.T) {
+	ts := newTestServer(nil, dto.LinkConfig{Secret: "shared"})
+	if auth := ts.link.Load(); auth.pingTimeout != 0 {
+		t.Fatalf("pingTimeout = %v, want disabled by default", auth.pingTimeout)
+	}
+	ts.link.Load().pingInterval = 20 * time.Millisecond
+	defer ts.Stop()
+
+	c, r := connectInstance(t, ts, "wingo-1")
+	waitInstances(t, ts, 1)
+
+	// 不回复 pong 的游戏服务保持连接，也收不到 ping
+	_ = c.SetReadDeadline(time.Now().Add(150 * time.Millisecond))
+	if _, err := ReadFrame(r); err == nil {
+		t.Fatal("unexpected frame with heartbeat disabled")
+	}
+	if ts.Instances("wingo") != 1 {
+		t.Fatal("session closed with heartbeat disabled")
+	}
+
+	// 运行中开启后开始发送 ping
+	auth := *ts.link.Load()
+	auth.pingTimeout = time.Second
+	ts.link.Store(&auth)
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	payload, err := ReadFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := new(pb.TcpMessage)
+	if err = proto.Unmarshal(payload, packet); err != nil || packet.Event != EventPing {
+		t.Fatalf("unexpected frame %+v %v", packet, err)
+	}
+	if ts.Instances("wingo") != 1 {
+		t.Fatal("session closed right after enabling heartbeat")
+	}
+}
@@ -0,0 +1,112 @@
+package tcp
+
+import (
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+)
+
+const defaultHoldSize = 1024
+
+// heldMsg 游戏服务重连期间暂存的消息
+type heldMsg struct {
+	req    *dto.CommonReq
+	packet []byte
+}
+
+// reconnecting 全部实例离线且仍在宽限期内，调用方持有 g.mu
+func (g *gameGroup) reconnecting(now time.Time) bool {
+	return len(g.instances) == 0 && now.Before(g.graceUntil)
+}
+
+// hold 没有可用实例时暂存消息，返回 dto.CodeOK 表示已暂存
+//
+// 加锁后实例可能已经注册，此时返回按 uid 选择的实例，由调用方直接发送。
+func (g *gameGroup) hold(msg *dto.CommonReq, packet []byte, size int) (*gameSession, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.instances) > 0 {
+		return g.ring.Load().get(msg.UserId), dto.CodeOK
+	}
+	if !g.reconnecting(time.Now()) {
+		return nil, dto.CodeGameOffline
+	}
+	if len(g.held) >= size {
+		return nil, dto.CodeQueueFull
+	}
+	g.held = append(g.held, heldMsg{req: msg, packet: packet})
+	return nil, dto.CodeOK
+}
+
+// flush 按顺序发给新注册的实例，调用方持有 g.mu，返回发送失败的消息
+func (g *gameGroup) flush(s *gameSession) (failed []*dto.CommonReq) {
+	for _, m := range g.held {
+		if s.push(m.packet) != nil {
+			failed = append(failed, m.req)
+		}
+	}
+	g.held = nil
+	g.graceUntil = time.Time{}
+	return failed
+}
+
+// expire 宽限期结束后仍未重连时取出全部暂存的消息
+func (g *gameGroup) expire() []*dto.CommonReq {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.instances) > 0 || g.reconnecting(time.Now()) {
+		return nil
+	}
+	expired := make([]*dto.CommonReq, 0, len(g.held))
+	for _, m := range g.held {
+		expired = append(expired, m.req)
+	}
+	g.held = nil
+	return expired
+}
+
+// Available 游戏服务在线，或全部实例断开后仍在重连宽限期内
+func (ts *TcpServer) Available(alias string) bool {
+	g, ok := ts.gameConn.Load(alias)
+	if !ok {
+		return false
+	}
+	group := g.(*gameGroup)
+	if group.count() > 0 {
+		return true
+	}
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	return group.reconnecting(time.Now())
+}
+
+// route 选择接收消息的实例，全部实例离线时在宽限期内暂存消息
+//
+// 返回的 session 为 nil 时，code 为 dto.CodeOK 表示消息已暂存，否则为无法发送的原因。
+func (ts *TcpServer) route(msg *dto.CommonReq, packet []byte) (*gameSession, int) {
+	g, ok := ts.gameConn.Load(msg.Server)
+	if !ok {
+		return nil, dto.CodeGameOffline
+	}
+	group := g.(*gameGroup)
+	if session := group.pick(msg.UserId, msg.Instance); session != nil {
+		return session, dto.CodeOK
+	}
+	// 指定实例的消息不暂存
+	if msg.Instance != "" {
+		return nil, dto.CodeGameOffline
+	}
+	return group.hold(msg, packet, ts.link.Load().holdSize)
+}
+
+// expireHeld 宽限期结束后回复暂存的消息
+func (ts *TcpServer) expireHeld(g *gameGroup, alias string) {
+	expired := g.expire()
+	if len(expired) > 0 {
+		logger.Log.Warnf("TcpServer game server %s not reconnected in time, %d held messages expired", alias, len(expired))
+	}
+	for _, msg := range expired {
+		ts.reject(msg, dto.CodeGameOffline)
+	}
+}
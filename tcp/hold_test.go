@@ -0,0 +1,115 @@
+package tcp
+
+import (
+	"bufio"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	pb "github.com/aluka-7/game-gateway/tcp/proto"
+	"github.com/golang/protobuf/proto"
+)
+
+func readSeq(t *testing.T, r *bufio.Reader) int64 {
+	t.Helper()
+	payload, err := ReadFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := new(pb.TcpMessage)
+	if err = proto.Unmarshal(payload, packet); err != nil {
+		t.Fatal(err)
+	}
+	return packet.Seq
+}
+
+func TestHold(t *testing.T) {
+	inMsg := make(chan *dto.CommonReq)
+	outMsg := make(chan *dto.CommonRes, 8)
+	ts := newTestServer(nil, dto.LinkConfig{Secret: "shared"})
+	ts.inMsg, ts.outMsg = inMsg, outMsg
+	auth := ts.link.Load()
+	auth.grace, auth.holdSize = 200*time.Millisecond, 3
+	defer ts.Stop()
+	go ts.dispatchLoop()
+	defer close(inMsg)
+	send := func(seq int64) {
+		inMsg <- &dto.CommonReq{Server: "wingo", Event: "bet", Seq: seq, UserId: 10001}
+	}
+	expect := func(seq int64, code int) {
+		t.Helper()
+		select {
+		case res := <-outMsg:
+			if res.Seq != seq || res.Code != code {
+				t.Fatalf("got seq %d code %d, want seq %d code %d", res.Seq, res.Code, seq, code)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no reply for seq %d", seq)
+		}
+	}
+
+	// 从未上线的游戏服务立即回复不在线
+	if ts.Available("wingo") {
+		t.Fatal("unknown game available")
+	}
+	send(1)
+	expect(1, dto.CodeGameOffline)
+
+	// 重启期间暂存，重连后按顺序发送，之后的消息排在后面
+	c, _ := connectInstance(t, ts, "wingo-1")
+	waitInstances(t, ts, 1)
+	_ = c.Close()
+	waitInstances(t, ts, 0)
+	if !ts.Available("wingo") {
+		t.Fatal("reconnecting game not available")
+	}
+	for seq := int64(2); seq <= 4; seq++ {
+		send(seq)
+	}
+	// 超过 holdSize 的消息立即回复
+	send(5)
+	expect(5, dto.CodeQueueFull)
+	_, r := connectInstance(t, ts, "wingo-2")
+	waitInstances(t, ts, 1)
+	send(6)
+	for seq := int64(2); seq <= 6; seq++ {
+		if seq == 5 {
+			continue
+		}
+		if got := readSeq(t, r); got != seq {
+			t.Fatalf("got seq %d, want %d", got, seq)
+		}
+	}
+
+	// 宽限期内未重连时暂存的消息回复不在线
+	ts.group("wingo").pick(0, "wingo-2").close()
+	waitInstances(t, ts, 0)
+	send(7)
+	expect(7, dto.CodeGameOffline)
+	if ts.Available("wingo") {
+		t.Fatal("game still available after grace period")
+	}
+	send(8)
+	expect(8, dto.CodeGameOffline)
+	if len(outMsg) != 0 {
+		t.Fatalf("unexpected replies %d", len(outMsg))
+	}
+}
+
+func TestHoldCapacity(t *testing.T) {
+	if auth := newLinkAuth(dto.LinkConfig{HoldSize: 4 * defaultSendBufSize}); auth.holdSize != defaultSendBufSize {
+		t.Fatalf("holdSize = %d, want %d", auth.holdSize, defaultSendBufSize)
+	}
+
+	// 暂存满的消息在重连时全部放入新会话的发送队列
+	g := newGameGroup()
+	g.graceUntil = time.Now().Add(time.Minute)
+	for seq := int64(1); seq <= defaultSendBufSize; seq++ {
+		if _, code := g.hold(&dto.CommonReq{Seq: seq, UserId: 10001}, []byte{1}, defaultSendBufSize); code != dto.CodeOK {
+			t.Fatalf("seq %d not held: %d", seq, code)
+		}
+	}
+	if _, failed := g.add(newTestSession(t, "wingo-1")); len(failed) != 0 {
+		t.Fatalf("%d held messages failed on flush", len(failed))
+	}
+}
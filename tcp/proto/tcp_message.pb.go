@@ -30,6 +30,9 @@ type TcpMessage struct {
 	Code          int32                  `protobuf:"varint,5,opt,name=code,proto3" json:"code,omitempty"`
 	Msg           string                 `protobuf:"bytes,6,opt,name=msg,proto3" json:"msg,omitempty"`
 	Data          []byte                 `protobuf:"bytes,7,opt,name=data,proto3" json:"data,omitempty"`
+	Room          string                 `protobuf:"bytes,8,opt,name=room,proto3" json:"room,omitempty"`
+	UserIds       []int64                `protobuf:"varint,9,rep,packed,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	ReportOffline bool                   `protobuf:"varint,10,opt,name=report_offline,json=reportOffline,proto3" json:"report_offline,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -113,11 +116,32 @@ func (x *TcpMessage) GetData() []byte {
 	return nil
 }
 
+func (x *TcpMessage) GetRoom() string {
+	if x != nil {
+		return x.Room
+	}
+	return ""
+}
+
+func (x *TcpMessage) GetUserIds() []int64 {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+func (x *TcpMessage) GetReportOffline() bool {
+	if x != nil {
+		return x.ReportOffline
+	}
+	return false
+}
+
 var File_tcp_message_proto protoreflect.FileDescriptor
 
 const file_tcp_message_proto_rawDesc = "" +
 	"\n" +
-	"\x11tcp_message.proto\x12\x03tcp\"\x9f\x01\n" +
+	"\x11tcp_message.proto\x12\x03tcp\"\xf5\x01\n" +
 	"\n" +
 	"TcpMessage\x12\x16\n" +
 	"\x06server\x18\x01 \x01(\tR\x06server\x12\x14\n" +
@@ -126,7 +150,11 @@ const file_tcp_message_proto_rawDesc = "" +
 	"\auser_id\x18\x04 \x01(\x03R\x06userId\x12\x12\n" +
 	"\x04code\x18\x05 \x01(\x05R\x04code\x12\x10\n" +
 	"\x03msg\x18\x06 \x01(\tR\x03msg\x12\x12\n" +
-	"\x04data\x18\a \x01(\fR\x04dataB%Z#github.com/aluka-7/game-gateway/tcpb\x06proto3"
+	"\x04data\x18\a \x01(\fR\x04data\x12\x12\n" +
+	"\x04room\x18\b \x01(\tR\x04room\x12\x19\n" +
+	"\buser_ids\x18\t \x03(\x03R\auserIds\x12%\n" +
+	"\x0ereport_offline\x18\n" +
+	" \x01(\bR\rreportOfflineB%Z#github.com/aluka-7/game-gateway/tcpb\x06proto3"
 
 var (
 	file_tcp_message_proto_rawDescOnce sync.Once
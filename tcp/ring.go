@@ -0,0 +1,157 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+)
+
+// virtualNodes 每个实例在哈希环上的虚拟节点数，节点越多分布越均匀
+const virtualNodes = 160
+
+// ring 一致性哈希环，构建后只读，实例变化时整体替换
+type ring struct {
+	hashes   []uint64
+	sessions []*gameSession // 与 hashes 一一对应
+}
+
+func hash64(b []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	// fnv 的低位分布较差，再混合一次
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	return x
+}
+
+func newRing(sessions []*gameSession) *ring {
+	r := &ring{
+		hashes:   make([]uint64, 0, len(sessions)*virtualNodes),
+		sessions: make([]*gameSession, 0, len(sessions)*virtualNodes),
+	}
+	type node struct {
+		hash    uint64
+		session *gameSession
+	}
+	nodes := make([]node, 0, len(sessions)*virtualNodes)
+	for _, s := range sessions {
+		for i := 0; i < virtualNodes; i++ {
+			nodes = append(nodes, node{hash64([]byte(s.instance + "#" + strconv.Itoa(i))), s})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].hash < nodes[j].hash })
+	for _, n := range nodes {
+		r.hashes = append(r.hashes, n.hash)
+		r.sessions = append(r.sessions, n.session)
+	}
+	return r
+}
+
+// get 返回 uid 顺时针方向的第一个实例，环为空时返回 nil
+func (r *ring) get(uid int64) *gameSession {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(uid))
+	h := hash64(b[:])
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.sessions[i]
+}
+
+// gameGroup 同一游戏服务别名的全部实例
+//
+// 实例加入或离开时只有落在其虚拟节点上的用户改变实例，其余用户保持不变。
+type gameGroup struct {
+	mu        sync.Mutex
+	instances map[string]*gameSession
+	ring      atomic.Pointer[ring]
+
+	// 全部实例断开后暂存消息，直到 graceUntil
+	held       []heldMsg
+	graceUntil time.Time
+}
+
+func newGameGroup() *gameGroup {
+	g := &gameGroup{instances: make(map[string]*gameSession)}
+	g.ring.Store(newRing(nil))
+	return g
+}
+
+// add 加入实例，返回被替换的同一实例id的旧会话，以及暂存后发送失败的消息
+//
+// 暂存的消息先于更新哈希环发给新实例，之后的消息不会越过它们。
+func (g *gameGroup) add(s *gameSession) (old *gameSession, failed []*dto.CommonReq) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.instances) == 0 {
+		failed = g.flush(s)
+	}
+	old = g.instances[s.instance]
+	g.instances[s.instance] = s
+	g.rebuild()
+	return old, failed
+}
+
+// remove 移除实例，实例已被新会话替换时不做处理
+//
+// 最后一个实例离开且 grace 大于 0 时进入重连宽限期，返回 true。
+func (g *gameGroup) remove(s *gameSession, grace time.Duration) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.instances[s.instance] != s {
+		return false
+	}
+	delete(g.instances, s.instance)
+	g.rebuild()
+	if len(g.instances) > 0 || grace <= 0 {
+		return false
+	}
+	g.graceUntil = time.Now().Add(grace)
+	return true
+}
+
+func (g *gameGroup) rebuild() {
+	sessions := make([]*gameSession, 0, len(g.instances))
+	for _, s := range g.instances {
+		sessions = append(sessions, s)
+	}
+	g.ring.Store(newRing(sessions))
+}
+
+// pick 按 instance 选择实例，instance 为空时按 uid 一致性哈希选择
+func (g *gameGroup) pick(uid int64, instance string) *gameSession {
+	if instance != "" {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.instances[instance]
+	}
+	return g.ring.Load().get(uid)
+}
+
+// count 在线实例数
+func (g *gameGroup) count() int {
+	return len(g.ring.Load().hashes) / virtualNodes
+}
+
+// all 返回全部实例
+func (g *gameGroup) all() []*gameSession {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	sessions := make([]*gameSession, 0, len(g.instances))
+	for _, s := range g.instances {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
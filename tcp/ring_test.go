@@ -0,0 +1,190 @@
+package tcp
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	pb "github.com/aluka-7/game-gateway/tcp/proto"
+	"github.com/golang/protobuf/proto"
+)
+
+func newTestSession(t *testing.T, instance string) *gameSession {
+	t.Helper()
+	a, b := net.Pipe()
+	t.Cleanup(func() { _ = b.Close() })
+	s := newGameSession("game", instance, a, nil)
+	t.Cleanup(s.close)
+	return s
+}
+
+func TestRingBalance(t *testing.T) {
+	sessions := []*gameSession{newTestSession(t, "a"), newTestSession(t, "b"), newTestSession(t, "c")}
+	r := newRing(sessions)
+	counts := make(map[string]int)
+	const users = 30000
+	for uid := int64(1); uid <= users; uid++ {
+		s := r.get(uid)
+		if s != r.get(uid) {
+			t.Fatalf("uid %d not sticky", uid)
+		}
+		counts[s.instance]++
+	}
+	for _, s := range sessions {
+		// 每个实例的负载在平均值的 ±20% 以内
+		if n := counts[s.instance]; n < users/3*8/10 || n > users/3*12/10 {
+			t.Fatalf("instance %s got %d of %d users", s.instance, n, users)
+		}
+	}
+	if newRing(nil).get(1) != nil {
+		t.Fatal("empty ring should return nil")
+	}
+}
+
+func TestRingRebalance(t *testing.T) {
+	a, b, c := newTestSession(t, "a"), newTestSession(t, "b"), newTestSession(t, "c")
+	before := newRing([]*gameSession{a, b})
+	after := newRing([]*gameSession{a, b, c})
+	moved := 0
+	const users = 30000
+	for uid := int64(1); uid <= users; uid++ {
+		from, to := before.get(uid), after.get(uid)
+		if from != to {
+			// 新实例加入时只有迁往新实例的用户改变实例
+			if to != c {
+				t.Fatalf("uid %d moved from %s to %s", uid, from.instance, to.instance)
+			}
+			moved++
+		}
+	}
+	if moved < users/3*8/10 || moved > users/3*12/10 {
+		t.Fatalf("moved %d of %d users", moved, users)
+	}
+}
+
+func TestGameGroup(t *testing.T) {
+	g := newGameGroup()
+	if g.count() != 0 || g.pick(1, "") != nil {
+		t.Fatal("empty group")
+	}
+	for i := 0; i < 3; i++ {
+		if old, _ := g.add(newTestSession(t, strconv.Itoa(i))); old != nil {
+			t.Fatal("unexpected replaced session")
+		}
+	}
+	if g.count() != 3 {
+		t.Fatalf("count = %d", g.count())
+	}
+
+	// 同一实例id重连时替换旧会话，旧会话退出不影响新会话
+	old := g.pick(0, "1")
+	s := newTestSession(t, "1")
+	if replaced, _ := g.add(s); replaced != old {
+		t.Fatal("old session not returned")
+	}
+	g.remove(old, 0)
+	if g.count() != 3 || g.pick(0, "1") != s {
+		t.Fatal("replaced session removed the new one")
+	}
+
+	// 指定实例优先于一致性哈希
+	for uid := int64(1); uid <= 100; uid++ {
+		if g.pick(uid, "2").instance != "2" {
+			t.Fatal("instance not honored")
+		}
+	}
+	if g.pick(1, "missing") != nil {
+		t.Fatal("unknown instance should return nil")
+	}
+
+	g.remove(s, 0)
+	if g.count() != 2 || g.pick(0, "1") != nil {
+		t.Fatal("session not removed")
+	}
+	for uid := int64(1); uid <= 100; uid++ {
+		if g.pick(uid, "") == s {
+			t.Fatal("removed session still picked")
+		}
+	}
+}
+
+func TestSessionPushAfterClose(t *testing.T) {
+	s := newTestSession(t, "a")
+	if err := s.push([]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	s.close()
+	if err := s.push([]byte{1}); err != errSessionClosed {
+		t.Fatalf("push after close: %v", err)
+	}
+}
+
+// connectInstance 模拟游戏服务实例完成握手，返回游戏服务一端的连接
+func connectInstance(t *testing.T, ts *TcpServer, instance string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	go ts.serve(server)
+	frame, _ := EncodeHandshake(NewHandshake("wingo", instance, "shared"))
+	go func() { _, _ = client.Write(frame) }()
+	reader := bufio.NewReader(client)
+	if err := ReadHandshakeReply(reader); err != nil {
+		t.Fatal(err)
+	}
+	return client, reader
+}
+
+func waitInstances(t *testing.T, ts *TcpServer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for ts.Instances("wingo") != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("instances = %d, want %d", ts.Instances("wingo"), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServeInstances(t *testing.T) {
+	inMsg := make(chan *dto.CommonReq)
+	ts := newTestServer(nil, dto.LinkConfig{Secret: "shared"})
+	ts.inMsg = inMsg
+	defer ts.Stop()
+	go ts.dispatchLoop()
+	defer close(inMsg)
+
+	c1, r1 := connectInstance(t, ts, "wingo-1")
+	_, r2 := connectInstance(t, ts, "wingo-2")
+	waitInstances(t, ts, 2)
+	if counts := ts.InstanceCounts(); counts["wingo"] != 2 {
+		t.Fatalf("counts = %v", counts)
+	}
+
+	// 同一用户的消息总是发给同一实例
+	want := ts.group("wingo").pick(7, "")
+	reader := r1
+	if want.instance == "wingo-2" {
+		reader = r2
+	}
+	for seq := int64(1); seq <= 3; seq++ {
+		inMsg <- &dto.CommonReq{Server: "wingo", Event: "bet", Seq: seq, UserId: 7}
+		payload, err := ReadFrame(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := new(pb.TcpMessage)
+		if err = proto.Unmarshal(payload, packet); err != nil || packet.Seq != seq {
+			t.Fatalf("seq %d: %+v %v", seq, packet, err)
+		}
+	}
+
+	// 实例离开后其余实例继续服务
+	_ = c1.Close()
+	waitInstances(t, ts, 1)
+	if !ts.Online("wingo") || ts.group("wingo").pick(7, "").instance != "wingo-2" {
+		t.Fatal("remaining instance not picked")
+	}
+}
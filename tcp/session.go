@@ -2,8 +2,10 @@ package tcp
 
 import (
 	"bufio"
+	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,27 +15,79 @@ const (
 	defaultSendBufSize  = 1024
 )
 
+var (
+	errSessionClosed   = errors.New("game session closed")
+	errQueueFull       = errors.New("game session queue full")
+	errSessionReplaced = errors.New("replaced by new connection")
+)
+
 type gameSession struct {
-	alias  string
-	conn   net.Conn
-	reader *bufio.Reader
+	alias    string
+	instance string // 握手时上报的实例id
+	conn     net.Conn
+	reader   *bufio.Reader
+
+	since    time.Time    // 注册时间
+	lastPong atomic.Int64 // 最近一次收到 pong 的时间，UnixNano
 
-	send chan []byte
-	once sync.Once
+	send   chan []byte
+	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
+	reason error // 网关主动关闭的原因
+}
+
+func newGameSession(alias, instance string, conn net.Conn, reader *bufio.Reader) *gameSession {
+	gs := &gameSession{
+		alias:    alias,
+		instance: instance,
+		conn:     conn,
+		reader:   reader,
+		since:    time.Now(),
+		send:     make(chan []byte, defaultSendBufSize),
+		done:     make(chan struct{}),
+	}
+	gs.lastPong.Store(gs.since.UnixNano())
+	return gs
 }
 
-func newGameSession(alias string, conn net.Conn, reader *bufio.Reader) *gameSession {
-	return &gameSession{
-		alias:  alias,
-		conn:   conn,
-		reader: reader,
-		send:   make(chan []byte, defaultSendBufSize),
+// push 加入发送队列，不会阻塞
+func (gs *gameSession) push(msg []byte) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if gs.closed {
+		return errSessionClosed
+	}
+	select {
+	case gs.send <- msg:
+		return nil
+	default:
+		return errQueueFull
 	}
 }
 
+// close 关闭会话，实例重新平衡时可能与 push 并发执行
 func (gs *gameSession) close() {
-	gs.once.Do(func() {
-		close(gs.send)
-		_ = gs.conn.Close()
-	})
+	gs.fail(nil)
+}
+
+// fail 以 reason 关闭会话，会话已关闭时不做处理
+func (gs *gameSession) fail(reason error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if gs.closed {
+		return
+	}
+	gs.closed = true
+	gs.reason = reason
+	close(gs.send)
+	close(gs.done)
+	_ = gs.conn.Close()
+}
+
+// err 网关主动关闭会话的原因，未关闭或没有原因时返回 nil
+func (gs *gameSession) err() error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.reason
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/aluka-7/game-gateway/utils/logger"
 	"io"
 	"net"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,7 +23,7 @@ type TcpServer struct {
 	listener net.Listener
 	stopOnce sync.Once
 
-	gameConn     sync.Map
+	gameConn     sync.Map // alias -> *gameGroup
 	allowedGames map[string]struct{}
 
 	// 上下文
@@ -36,11 +37,22 @@ type TcpServer struct {
 
 	ce     cache.Provider
 	closed atomic.Bool
+
+	// 握手校验配置
+	link atomic.Pointer[linkAuth]
+	// 记录握手 nonce，首次使用时返回 true
+	claimNonce func(key string, ttl time.Duration) bool
+
+	// 监听端口与主动连接的 endpoints，Run 之后才开始连接
+	connMu     sync.Mutex
+	running    bool
+	mode       string                // 启动时的 link.mode
+	connectors map[string]*connector // alias/addr -> connector
 }
 
-func NewTcpServer(addr string, ce cache.Provider, gameList []string, inMsg <-chan *dto.CommonReq, outMsg chan<- *dto.CommonRes) *TcpServer {
+func NewTcpServer(addr string, ce cache.Provider, gameList []string, link dto.LinkConfig, inMsg <-chan *dto.CommonReq, outMsg chan<- *dto.CommonRes) *TcpServer {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &TcpServer{
+	ts := &TcpServer{
 		addr:         addr,
 		ctx:          ctx,
 		gameConn:     sync.Map{},
@@ -49,22 +61,46 @@ func NewTcpServer(addr string, ce cache.Provider, gameList []string, inMsg <-cha
 		inMsg:        inMsg,
 		outMsg:       outMsg,
 		ce:           ce,
+		connectors:   make(map[string]*connector),
 	}
+	ts.link.Store(newLinkAuth(link))
+	ts.claimNonce = func(key string, ttl time.Duration) bool {
+		return ce.SetNX(ctx, key, "1", ttl)
+	}
+	return ts
 }
 
-// Run ...
+// SetLink 配置变更后更新握手校验配置，对新连接生效，并按新的 endpoints 增减主动连接
+func (ts *TcpServer) SetLink(cfg dto.LinkConfig) {
+	ts.link.Store(newLinkAuth(cfg))
+	ts.syncConnectors()
+}
+
+// Run 启动消息分发，按 link.mode 监听游戏服务连接或主动连接 endpoints
 func (ts *TcpServer) Run() {
+	go ts.dispatchLoop()
+
+	mode := ts.link.Load().mode
+	ts.startConnectors()
+	if mode == LinkModeConnect {
+		return
+	}
+
 	listener, err := net.Listen("tcp", ts.addr)
 	if err != nil {
 		logger.Log.Errorf("TcpServer Run Error: %+v", err)
 		return
 	}
-	ts.listener = listener
 	defer listener.Close()
+	// Stop 先于监听完成时直接退出
+	ts.connMu.Lock()
+	ts.listener = listener
+	ts.connMu.Unlock()
+	if ts.closed.Load() {
+		return
+	}
 	fmt.Println(fmt.Sprintf("⇨ tcp server started on \u001B[0;32;40m%s\u001B[0m", ts.addr))
 
-	go ts.dispatchLoop()
-
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -74,21 +110,40 @@ func (ts *TcpServer) Run() {
 			logger.Log.Errorf("TcpServer Run Accept Error: %+v", err)
 			return
 		}
-		reader := bufio.NewReader(conn)
-		alias, err := reader.ReadString('\n') // 获取游戏服务别名
-		if err != nil {
-			logger.Log.Errorf("TcpServer Run ReadString Error: %+v", err)
-			_ = conn.Close()
-			continue
-		}
-		cleanAlias := strings.TrimSpace(alias)
-		if !ts.isAllowedGame(cleanAlias) {
-			logger.Log.Warnf("TcpServer reject unknown game alias: %s", cleanAlias)
-			_ = conn.Close()
-			continue
+		go ts.serve(conn)
+	}
+}
+
+// serve 在独立协程中完成握手，超时未完成握手的连接被关闭，不会阻塞 accept
+func (ts *TcpServer) serve(conn net.Conn) {
+	h, reader, err := ts.accept(conn, "")
+	if err != nil {
+		return
+	}
+	ts.handleRequest(h.Alias, h.InstanceId, conn, reader)
+}
+
+// accept 完成握手并回复，失败时关闭连接。无论连接由哪一方发起，都由游戏服务发送握手帧
+func (ts *TcpServer) accept(conn net.Conn, alias string) (*Handshake, *bufio.Reader, error) {
+	reader := bufio.NewReaderSize(conn, defaultReadBufSize)
+	_ = conn.SetDeadline(time.Now().Add(ts.link.Load().timeout))
+	h, err := ts.handshake(reader, alias)
+	if err != nil {
+		logger.Log.Warnf("TcpServer reject game server %s: %v", conn.RemoteAddr().String(), err)
+		if !errors.Is(err, os.ErrDeadlineExceeded) && !errors.Is(err, io.EOF) {
+			_, _ = conn.Write(handshakeReply(err))
 		}
-		go ts.handleRequest(cleanAlias, conn, reader)
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	if _, err = conn.Write(handshakeReply(nil)); err != nil {
+		logger.Log.Errorf("TcpServer write handshake reply error: %+v", err)
+		_ = conn.Close()
+		return nil, nil, err
 	}
+	_ = conn.SetDeadline(time.Time{})
+	logger.Log.Infof("TcpServer game server %s instance %s registered from %s", h.Alias, h.InstanceId, conn.RemoteAddr().String())
+	return h, reader, nil
 }
 
 func (ts *TcpServer) dispatchLoop() {
@@ -96,24 +151,45 @@ func (ts *TcpServer) dispatchLoop() {
 		packet, err := EncodeReq(msg)
 		if err != nil {
 			logger.Log.Errorf("TcpServer encode req error: %+v", err)
+			ts.reject(msg, dto.CodeBadRequest)
 			continue
 		}
-		if c, ok := ts.gameConn.Load(msg.Server); ok { // 发给对应游戏服务
-			session := c.(*gameSession)
-			if !ts.enqueueMessage(session, packet) {
-				logger.Log.Warnf("TcpServer drop msg to game server %s due to full queue", msg.Server)
-				continue
+		// 发给对应游戏服务，同一用户固定发给同一实例
+		session, code := ts.route(msg, packet)
+		if session == nil {
+			if code != dto.CodeOK {
+				ts.reject(msg, code)
 			}
+			continue
+		}
+		switch err = session.push(packet); {
+		case errors.Is(err, errQueueFull):
+			logger.Log.Warnf("TcpServer drop msg to game server %s instance %s due to full queue", msg.Server, session.instance)
+			ts.reject(msg, dto.CodeQueueFull)
+		case err != nil:
+			ts.reject(msg, dto.CodeGameOffline)
 		}
 	}
 }
 
-func (ts *TcpServer) enqueueMessage(session *gameSession, msg []byte) bool {
+// reject 消息无法发给游戏服务时立即回复客户端，网关发给游戏服务的控制消息不回复
+func (ts *TcpServer) reject(msg *dto.CommonReq, code int) {
+	if msg.System || msg.UserId == 0 {
+		return
+	}
+	res := &dto.CommonRes{
+		Server: msg.Server,
+		Event:  msg.Event,
+		Seq:    msg.Seq,
+		UserId: msg.UserId,
+		Code:   code,
+		Msg:    dto.CodeMsg(code),
+	}
+	// 不阻塞分发，回复丢失时由请求超时兜底
 	select {
-	case session.send <- msg:
-		return true
+	case ts.outMsg <- res:
 	default:
-		return false
+		logger.Log.Warnf("TcpServer drop %s reply to user %d", dto.CodeMsg(code), msg.UserId)
 	}
 }
 
@@ -129,7 +205,8 @@ func buildAllowedGames(gameList []string) map[string]struct{} {
 	return allowedGames
 }
 
-func (ts *TcpServer) isAllowedGame(alias string) bool {
+// IsAllowedGame 判断游戏服务别名是否允许接入，未配置 gameList 时全部允许
+func (ts *TcpServer) IsAllowedGame(alias string) bool {
 	if len(ts.allowedGames) == 0 {
 		return true
 	}
@@ -137,37 +214,90 @@ func (ts *TcpServer) isAllowedGame(alias string) bool {
 	return ok
 }
 
+// Online 判断游戏服务当前是否至少有一个实例在线
+func (ts *TcpServer) Online(alias string) bool {
+	return ts.Instances(alias) > 0
+}
+
+// Instances 游戏服务当前在线的实例数
+func (ts *TcpServer) Instances(alias string) int {
+	if g, ok := ts.gameConn.Load(alias); ok {
+		return g.(*gameGroup).count()
+	}
+	return 0
+}
+
+// InstanceCounts 全部游戏服务的在线实例数，实例全部离线的服务计为 0
+func (ts *TcpServer) InstanceCounts() map[string]int {
+	counts := make(map[string]int)
+	ts.gameConn.Range(func(key, value any) bool {
+		counts[key.(string)] = value.(*gameGroup).count()
+		return true
+	})
+	return counts
+}
+
+// group 返回别名对应的实例组，不存在时创建
+func (ts *TcpServer) group(alias string) *gameGroup {
+	if g, ok := ts.gameConn.Load(alias); ok {
+		return g.(*gameGroup)
+	}
+	g, _ := ts.gameConn.LoadOrStore(alias, newGameGroup())
+	return g.(*gameGroup)
+}
+
 func (ts *TcpServer) Stop() {
 	ts.stopOnce.Do(func() {
 		ts.closed.Store(true)
 		ts.cancel()
+		ts.connMu.Lock()
 		if ts.listener != nil {
 			_ = ts.listener.Close()
 		}
+		ts.connMu.Unlock()
 		ts.gameConn.Range(func(_, value any) bool {
-			value.(*gameSession).close()
+			for _, session := range value.(*gameGroup).all() {
+				session.close()
+			}
 			return true
 		})
 	})
 }
 
-func (ts *TcpServer) handleRequest(alias string, conn net.Conn, reader *bufio.Reader) {
-	session := newGameSession(alias, conn, reader)
-	if old, loaded := ts.gameConn.LoadOrStore(alias, session); loaded {
-		oldSession := old.(*gameSession)
-		oldSession.close()
-		ts.gameConn.Store(alias, session)
+func (ts *TcpServer) handleRequest(alias, instance string, conn net.Conn, reader *bufio.Reader) {
+	session := newGameSession(alias, instance, conn, reader)
+	// 同一实例id重连时替换旧连接，不同实例id同时在线
+	g := ts.group(alias)
+	old, failed := g.add(session)
+	if old != nil {
+		logger.Log.Infof("TcpServer game server %s instance %s replaced by new connection", alias, instance)
+		old.fail(errSessionReplaced)
+	}
+	for _, msg := range failed {
+		ts.reject(msg, dto.CodeQueueFull)
 	}
 	defer func() {
-		ts.gameConn.Delete(alias)
+		// 最后一个实例断开后，宽限期内的消息暂存到重连
+		grace := ts.link.Load().grace
+		if g.remove(session, grace) {
+			logger.Log.Infof("TcpServer game server %s offline, hold messages for %v", alias, grace)
+			time.AfterFunc(grace, func() {
+				ts.expireHeld(g, alias)
+			})
+		}
 		session.close()
 	}()
 
 	go ts.writeToGameServer(session)
+	go ts.keepalive(session)
 
 	for {
 		payload, err := ReadFrame(session.reader)
 		if err != nil {
+			if reason := session.err(); reason != nil {
+				logger.Log.Warnf("TcpServer game server %s instance %s closed: %v", alias, instance, reason)
+				return
+			}
 			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
 				return
 			}
@@ -180,6 +310,10 @@ func (ts *TcpServer) handleRequest(alias string, conn net.Conn, reader *bufio.Re
 			logger.Log.Errorf("TcpServer decode protobuf response error: %+v", err)
 			continue
 		}
+		if ts.heartbeat(session, res) {
+			continue
+		}
+		res.Instance = instance
 		select {
 		case ts.outMsg <- res:
 		case <-ts.ctx.Done():
@@ -192,8 +326,7 @@ func (ts *TcpServer) writeToGameServer(session *gameSession) {
 	for msg := range session.send {
 		_ = session.conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
 		if _, err := session.conn.Write(msg); err != nil {
-			logger.Log.Errorf("TcpServer Write Error: %+v", err)
-			session.close()
+			session.fail(fmt.Errorf("write: %w", err))
 			return
 		}
 	}
@@ -0,0 +1,53 @@
+package tcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+)
+
+func TestDispatchReject(t *testing.T) {
+	inMsg := make(chan *dto.CommonReq)
+	outMsg := make(chan *dto.CommonRes, 4)
+	ts := newTestServer(nil, dto.LinkConfig{})
+	ts.inMsg, ts.outMsg = inMsg, outMsg
+	defer ts.Stop()
+	go ts.dispatchLoop()
+	defer close(inMsg)
+
+	expect := func(code int) {
+		t.Helper()
+		select {
+		case res := <-outMsg:
+			if res.Code != code || res.Server != "wingo" || res.Event != "bet" || res.Seq != 1 || res.UserId != 10001 {
+				t.Fatalf("unexpected reply %+v", res)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no reply with code %d", code)
+		}
+	}
+
+	// 游戏服务不在线
+	inMsg <- &dto.CommonReq{Server: "wingo", Event: "bet", Seq: 1, UserId: 10001}
+	expect(dto.CodeGameOffline)
+
+	// 网关的控制消息不回复
+	inMsg <- &dto.CommonReq{Server: "wingo", Event: "offline_users", UserIds: []int64{10001}, System: true}
+
+	// 发送队列已满
+	s := newTestSession(t, "wingo-1")
+	ts.group("wingo").add(s)
+	for s.push([]byte{1}) == nil {
+	}
+	inMsg <- &dto.CommonReq{Server: "wingo", Event: "bet", Seq: 1, UserId: 10001}
+	expect(dto.CodeQueueFull)
+
+	// 实例已关闭
+	s.close()
+	inMsg <- &dto.CommonReq{Server: "wingo", Event: "bet", Seq: 1, UserId: 10001}
+	expect(dto.CodeGameOffline)
+	if len(outMsg) != 0 {
+		t.Fatalf("unexpected replies %d", len(outMsg))
+	}
+}
@@ -0,0 +1,205 @@
+// Description：分层时间轮，用于大量连接的超时管理
+
+package timewheel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	slotBits = 6
+	slotNum  = 1 << slotBits
+	slotMask = slotNum - 1
+	levelNum = 4 // 可表示 64^4 个刻度，超出时按最大值处理
+)
+
+// Timer 时间轮中的定时器，可通过 Reset 重复使用
+type Timer struct {
+	fn     func()
+	expire uint64 // 到期刻度
+	slot   *slot
+	prev   *Timer
+	next   *Timer
+}
+
+// slot 双向链表，哨兵节点本身不计入
+type slot struct {
+	head Timer
+}
+
+func (s *slot) init() {
+	s.head.prev, s.head.next = &s.head, &s.head
+}
+
+func (s *slot) push(t *Timer) {
+	t.slot = s
+	t.prev, t.next = s.head.prev, &s.head
+	s.head.prev.next = t
+	s.head.prev = t
+}
+
+func (s *slot) remove(t *Timer) {
+	t.prev.next, t.next.prev = t.next, t.prev
+	t.prev, t.next, t.slot = nil, nil, nil
+}
+
+// take 取出全部定时器并清空
+func (s *slot) take() *Timer {
+	if s.head.next == &s.head {
+		return nil
+	}
+	first := s.head.next
+	s.head.prev.next = nil
+	s.init()
+	return first
+}
+
+// TimeWheel 分层时间轮，每个刻度只处理到期及需要降级的定时器，与定时器总数无关
+type TimeWheel struct {
+	mu      sync.Mutex
+	tick    time.Duration
+	start   time.Time
+	current uint64 // 下一个待处理的刻度
+	levels  [levelNum][slotNum]slot
+}
+
+func New(tick time.Duration) *TimeWheel {
+	tw := &TimeWheel{tick: tick, start: time.Now()}
+	for l := range tw.levels {
+		for i := range tw.levels[l] {
+			tw.levels[l][i].init()
+		}
+	}
+	return tw
+}
+
+// NewTimer 创建未启动的定时器，fn 在时间轮的 goroutine 中执行，不能阻塞
+func (tw *TimeWheel) NewTimer(fn func()) *Timer {
+	return &Timer{fn: fn}
+}
+
+// AfterFunc 在 d 之后执行 fn
+func (tw *TimeWheel) AfterFunc(d time.Duration, fn func()) *Timer {
+	t := tw.NewTimer(fn)
+	tw.Reset(t, d)
+	return t
+}
+
+// Reset 重新设置定时器在 d 之后执行，已在等待中的定时器先被移除
+func (tw *TimeWheel) Reset(t *Timer, d time.Duration) {
+	ticks := uint64(0)
+	if d > 0 {
+		ticks = uint64((d + tw.tick - 1) / tw.tick)
+	}
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if t.slot != nil {
+		t.slot.remove(t)
+	}
+	t.expire = tw.now() + ticks
+	tw.add(t)
+}
+
+// Stop 停止定时器，返回定时器是否仍在等待中
+func (tw *TimeWheel) Stop(t *Timer) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if t.slot == nil {
+		return false
+	}
+	t.slot.remove(t)
+	return true
+}
+
+// now 当前已经过的刻度，尚未处理的刻度不会早于 current
+func (tw *TimeWheel) now() uint64 {
+	elapsed := uint64(time.Since(tw.start) / tw.tick)
+	if elapsed < tw.current {
+		return tw.current
+	}
+	return elapsed
+}
+
+func (tw *TimeWheel) add(t *Timer) {
+	expire := t.expire
+	if expire < tw.current {
+		expire = tw.current
+	}
+	delta := expire - tw.current
+	for l := 0; l < levelNum; l++ {
+		if delta < 1<<(slotBits*(l+1)) || l == levelNum-1 {
+			if l == levelNum-1 && delta >= 1<<(slotBits*levelNum) {
+				expire = tw.current + 1<<(slotBits*levelNum) - 1
+				t.expire = expire
+			}
+			tw.levels[l][(expire>>(slotBits*l))&slotMask].push(t)
+			return
+		}
+	}
+}
+
+// Advance 处理截至 now 的全部刻度，返回执行的定时器数量
+//
+// 定时器在锁外依次执行，回调中可以对任意定时器调用 Reset/Stop。同一时间轮只应由一个 goroutine 驱动。
+func (tw *TimeWheel) Advance(now time.Time) int {
+	target := uint64(now.Sub(tw.start) / tw.tick)
+	n := 0
+	var expired []*Timer
+	for {
+		tw.mu.Lock()
+		if tw.current > target {
+			tw.mu.Unlock()
+			return n
+		}
+		expired = tw.step(expired[:0])
+		tw.mu.Unlock()
+		for i, t := range expired {
+			t.fn()
+			expired[i] = nil
+		}
+		n += len(expired)
+	}
+}
+
+// step 处理 current 刻度，将到期的定时器追加到 expired
+func (tw *TimeWheel) step(expired []*Timer) []*Timer {
+	idx := tw.current & slotMask
+	if idx == 0 {
+		// 上层刻度依次降级
+		for l := 1; l < levelNum; l++ {
+			i := (tw.current >> (slotBits * l)) & slotMask
+			for t := tw.levels[l][i].take(); t != nil; {
+				next := t.next
+				tw.add(t)
+				t = next
+			}
+			if i != 0 {
+				break
+			}
+		}
+	}
+	for t := tw.levels[0][idx].take(); t != nil; {
+		next := t.next
+		t.prev, t.next, t.slot = nil, nil, nil
+		expired = append(expired, t)
+		t = next
+	}
+	tw.current++
+	return expired
+}
+
+// Run 按刻度驱动时间轮，直到 ctx 结束
+func (tw *TimeWheel) Run(ctx context.Context) {
+	ticker := time.NewTicker(tw.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			tw.Advance(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
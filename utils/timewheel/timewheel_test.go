@@ -0,0 +1,128 @@
+package timewheel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// advanceTicks 逐个刻度推进时间轮
+func advanceTicks(tw *TimeWheel, from, to uint64) {
+	for i := from; i <= to; i++ {
+		tw.Advance(tw.start.Add(time.Duration(i) * tw.tick))
+	}
+}
+
+func TestTimeWheelExpire(t *testing.T) {
+	tw := New(time.Millisecond)
+	delays := []uint64{0, 1, 63, 64, 65, 200, 4095, 4096, 5000, 300000}
+	fired := make(map[uint64]uint64)
+	var current uint64
+	for _, d := range delays {
+		d := d
+		tw.AfterFunc(time.Duration(d)*time.Millisecond, func() {
+			fired[d] = current
+		})
+	}
+	// 创建定时器时已经过去的刻度
+	base := tw.now()
+	for current = 0; current <= base+300001; current++ {
+		tw.Advance(tw.start.Add(time.Duration(current) * tw.tick))
+	}
+	for _, d := range delays {
+		at, ok := fired[d]
+		if !ok {
+			t.Fatalf("timer %d not fired", d)
+		}
+		if at < d || at > base+d {
+			t.Fatalf("timer %d fired at tick %d", d, at)
+		}
+	}
+}
+
+func TestTimeWheelStopReset(t *testing.T) {
+	tw := New(time.Millisecond)
+	n := 0
+	timer := tw.AfterFunc(10*time.Millisecond, func() { n++ })
+	if !tw.Stop(timer) || tw.Stop(timer) {
+		t.Fatal("unexpected Stop result")
+	}
+	advanceTicks(tw, 0, tw.now()+20)
+	if n != 0 {
+		t.Fatal("stopped timer fired")
+	}
+
+	tw.Reset(timer, 5*time.Millisecond)
+	tw.Reset(timer, 100*time.Millisecond)
+	now := tw.now()
+	advanceTicks(tw, now-20, now+50)
+	if n != 0 {
+		t.Fatal("timer fired before reset deadline")
+	}
+	advanceTicks(tw, now+51, now+101)
+	if n != 1 {
+		t.Fatalf("timer fired %d times", n)
+	}
+}
+
+func TestTimeWheelRescheduleInCallback(t *testing.T) {
+	tw := New(time.Millisecond)
+	n := 0
+	var timer *Timer
+	timer = tw.AfterFunc(time.Millisecond, func() {
+		n++
+		if n < 3 {
+			tw.Reset(timer, 10*time.Millisecond)
+		}
+	})
+	// 回调中使用墙上时间计算到期刻度，推进到足够远
+	time.Sleep(50 * time.Millisecond)
+	advanceTicks(tw, 0, tw.now()+100)
+	if n != 3 {
+		t.Fatalf("timer fired %d times", n)
+	}
+}
+
+// BenchmarkTimeWheelTick 全部连接的定时器均匀分布在真实的超时时间内，到期后按 checkConn 的方式重新计时，
+// 通过 Advance 逐个刻度推进，计入上层刻度的降级。每个刻度的开销与到期的定时器数量成正比，
+// 每个到期定时器的开销（ns/timer）与连接总数无关。
+func BenchmarkTimeWheelTick(b *testing.B) {
+	const tick = 100 * time.Millisecond
+	deadlines := []struct {
+		name    string
+		timeout time.Duration
+	}{
+		{"auth=5s", 5 * time.Second},
+		{"heartbeat=30s", 30 * time.Second},
+	}
+	for _, d := range deadlines {
+		for _, conns := range []int{5000, 50000, 500000} {
+			b.Run(fmt.Sprintf("%s/conns=%d", d.name, conns), func(b *testing.B) {
+				tw := New(tick)
+				ticks := int(d.timeout / tick)
+				for i := 0; i < conns; i++ {
+					var t *Timer
+					t = tw.NewTimer(func() { tw.Reset(t, d.timeout) })
+					tw.Reset(t, time.Duration(i*ticks/conns)*tick)
+				}
+				// 先运行一个完整周期，进入稳定状态
+				cursor := uint64(0)
+				for ; cursor < uint64(2*ticks); cursor++ {
+					tw.Advance(tw.start.Add(time.Duration(cursor) * tick))
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				fired := 0
+				for i := 0; i < b.N; i++ {
+					fired += tw.Advance(tw.start.Add(time.Duration(cursor) * tick))
+					cursor++
+				}
+				b.StopTimer()
+				b.ReportMetric(float64(fired)/float64(b.N), "timers/tick")
+				if fired > 0 {
+					b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(fired), "ns/timer")
+				}
+			})
+		}
+	}
+}
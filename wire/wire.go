@@ -10,13 +10,12 @@ import (
 	"github.com/aluka-7/game-gateway/dto"
 	"github.com/aluka-7/game-gateway/ws"
 	"github.com/google/wire"
-	"github.com/panjf2000/gnet/v2"
 )
 
 const (
 	SystemId = "10000"
 )
 
-func InitializeWsServer(*dto.GatewayConfig, cache.Provider, string) gnet.EventHandler {
+func InitializeWsServer(*dto.Gateway, cache.Provider, string) *ws.Server {
 	panic(wire.Build(ws.NewWsServer))
 }
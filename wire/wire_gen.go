@@ -10,14 +10,13 @@ import (
 	"github.com/aluka-7/cache"
 	"github.com/aluka-7/game-gateway/dto"
 	"github.com/aluka-7/game-gateway/ws"
-	"github.com/panjf2000/gnet/v2"
 )
 
 // Injectors from wire.go:
 
-func InitializeWsServer(gatewayConfig *dto.GatewayConfig, provider cache.Provider, string2 string) gnet.EventHandler {
-	eventHandler := ws.NewWsServer(gatewayConfig, provider, string2)
-	return eventHandler
+func InitializeWsServer(gateway *dto.Gateway, provider cache.Provider, string2 string) *ws.Server {
+	server := ws.NewWsServer(gateway, provider, string2)
+	return server
 }
 
 // wire.go:
@@ -0,0 +1,117 @@
+package ws
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+// adminPolicy 管理接口配置，配置变更后立即生效
+type adminPolicy struct {
+	token      string
+	announcers map[string]struct{}
+}
+
+func newAdminPolicy(cfg dto.AdminConfig) *adminPolicy {
+	p := &adminPolicy{token: cfg.Token, announcers: make(map[string]struct{}, len(cfg.Announcers))}
+	for _, alias := range cfg.Announcers {
+		p.announcers[alias] = struct{}{}
+	}
+	return p
+}
+
+// authorized 校验 Authorization: Bearer <token>，未配置 token 时拒绝全部请求
+func (p *adminPolicy) authorized(header string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	return ok && p.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(p.token)) == 1
+}
+
+// RegisterAdmin 在 web 服务上注册管理接口
+func (w *Server) RegisterAdmin(eng *echo.Echo) {
+	g := eng.Group("/admin", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !w.admin.Load().authorized(c.Request().Header.Get(echo.HeaderAuthorization)) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"msg": "unauthorized"})
+			}
+			return next(c)
+		}
+	})
+	g.POST("/announce", w.handleAnnounce)
+	g.GET("/games", w.handleGames)
+	g.GET("/links", w.handleLinks)
+	g.GET("/sessions", w.handleSessions)
+	g.GET("/stats", w.handleStats)
+}
+
+// Stats 网关启动以来的丢弃统计
+type Stats struct {
+	Outbound         OutboundStats `json:"outbound"`         // 下行发送队列各策略的处理数
+	LifecycleDropped uint64        `json:"lifecycleDropped"` // 队列已满丢弃的生命周期事件数
+}
+
+// handleStats GET /admin/stats 返回启动以来的丢弃统计
+func (w *Server) handleStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, Stats{Outbound: w.OutboundStats(), LifecycleDropped: w.lifecycleDropped.Load()})
+}
+
+// handleGames GET /admin/games 返回各游戏服务的在线实例数
+func (w *Server) handleGames(c echo.Context) error {
+	return c.JSON(http.StatusOK, w.tcpSrv.InstanceCounts())
+}
+
+// handleLinks GET /admin/links 返回网关主动连接的各 endpoint 的状态
+func (w *Server) handleLinks(c echo.Context) error {
+	return c.JSON(http.StatusOK, w.tcpSrv.EndpointStates())
+}
+
+// handleSessions GET /admin/sessions 返回各游戏服务会话及距上次心跳的时间
+func (w *Server) handleSessions(c echo.Context) error {
+	return c.JSON(http.StatusOK, w.tcpSrv.Sessions())
+}
+
+// handleAnnounce POST /admin/announce 发送全服公告，返回收到公告的会话数
+func (w *Server) handleAnnounce(c echo.Context) error {
+	var req dto.AnnounceReq
+	if err := c.Bind(&req); err != nil || (req.Msg == "" && len(req.Data) == 0) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"msg": "msg or data required"})
+	}
+	n := w.announce(req.Msg, req.Data)
+	logger.Log.Infof("admin announcement from %s delivered to %d sessions", c.RealIP(), n)
+	return c.JSON(http.StatusOK, map[string]int{"sessions": n})
+}
+
+// announce 发给全部已认证的会话，不区分绑定的游戏服务，返回会话数
+func (w *Server) announce(msg string, data json.RawMessage) int {
+	payload, err := json.Marshal(&dto.CommonRes{
+		Server: ServerSystem,
+		Event:  EventAnnounce,
+		Code:   dto.CodeOK,
+		Msg:    msg,
+		Data:   data,
+	})
+	if err != nil {
+		logger.Log.Error(err)
+		return 0
+	}
+	frame := newOutFrame(payload)
+	// 在锁外加入发送队列，广播期间不阻塞登录与断开
+	items := w.connMgr.Snapshot()
+	for _, item := range items {
+		w.enqueue(item.Client, frame)
+	}
+	return len(items)
+}
+
+// handleAnnounceControl 游戏服务发送全服公告，只有 admin.announcers 中的服务可以发送
+func (w *Server) handleAnnounceControl(msg *dto.CommonRes) {
+	if _, ok := w.admin.Load().announcers[msg.Server]; !ok {
+		logger.Log.Warnf("game server %s is not allowed to announce", msg.Server)
+		return
+	}
+	n := w.announce(msg.Msg, msg.Data)
+	logger.Log.Infof("announcement from game server %s delivered to %d sessions", msg.Server, n)
+}
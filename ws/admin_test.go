@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAdminAnnounce(t *testing.T) {
+	srv := newRoomServer()
+	srv.admin.Store(newAdminPolicy(dto.AdminConfig{Token: "secret"}))
+	a, _ := newRoomClient(srv, 10001)
+	b, client := newRoomClient(srv, 10002)
+	// 绑定游戏服务与否都能收到
+	srv.members.Bind(client, "wingo")
+
+	eng := echo.New()
+	srv.RegisterAdmin(eng)
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/announce", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		eng.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("", `{"msg":"maintenance"}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("missing token: status %d", rec.Code)
+	}
+	if rec := post("wrong", `{"msg":"maintenance"}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: status %d", rec.Code)
+	}
+	if rec := post("secret", `{}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty announcement: status %d", rec.Code)
+	}
+	rec := post("secret", `{"msg":"maintenance","data":{"at":1700000000}}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"sessions":2`) {
+		t.Fatalf("announce: status %d body %s", rec.Code, rec.Body.String())
+	}
+	var res dto.CommonRes
+	if len(a.writes) != 1 || len(b.writes) != 1 || json.Unmarshal(a.writes[0][2:], &res) != nil {
+		t.Fatalf("announce: a=%d b=%d", len(a.writes), len(b.writes))
+	}
+	if res.Server != ServerSystem || res.Event != EventAnnounce || res.Msg != "maintenance" {
+		t.Fatalf("unexpected announcement %+v", res)
+	}
+
+	// 未配置 token 时关闭管理接口
+	srv.admin.Store(newAdminPolicy(dto.AdminConfig{}))
+	if rec := post("", `{"msg":"maintenance"}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("disabled admin: status %d", rec.Code)
+	}
+}
+
+func TestAnnounceControl(t *testing.T) {
+	srv := newRoomServer()
+	srv.admin.Store(newAdminPolicy(dto.AdminConfig{Announcers: []string{"ops"}}))
+	a, _ := newRoomClient(srv, 10001)
+
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: EventAnnounce, Msg: "spam", System: true})
+	if len(a.writes) != 0 {
+		t.Fatal("unprivileged game server announced")
+	}
+	srv.dispatch(&dto.CommonRes{Server: "ops", Event: EventAnnounce, Msg: "maintenance", System: true})
+	if len(a.writes) != 1 {
+		t.Fatal("privileged announcement not delivered")
+	}
+}
+
+func TestAdminStats(t *testing.T) {
+	srv := newRoomServer()
+	srv.admin.Store(newAdminPolicy(dto.AdminConfig{Token: "secret"}))
+	srv.outStats.droppedOldest.Add(3)
+	srv.outStats.disconnected.Add(1)
+	srv.lifecycleDropped.Add(2)
+
+	eng := echo.New()
+	srv.RegisterAdmin(eng)
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+	rec := httptest.NewRecorder()
+	eng.ServeHTTP(rec, req)
+	var stats Stats
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &stats) != nil {
+		t.Fatalf("stats: status %d body %s", rec.Code, rec.Body.String())
+	}
+	if stats.Outbound.DroppedOldest != 3 || stats.Outbound.Disconnected != 1 || stats.LifecycleDropped != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -1,15 +1,34 @@
 package ws
 
 import (
+	"context"
 	"errors"
 	"github.com/aluka-7/cache"
+	"github.com/aluka-7/game-gateway/dto"
 	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var jwtSecret = []byte("kX9Gxcd1-@0eV-*1")
+const (
+	// tokenCacheTTL jwt-id 本地缓存时长，避免每次认证都访问 redis
+	tokenCacheTTL = 3 * time.Second
+	// tokenCacheSweep 本地缓存条目超过该数量时清理过期条目
+	tokenCacheSweep = 1 << 16
+)
+
+var (
+	ErrTokenMissing   = errors.New("token missing")
+	ErrTokenMalformed = errors.New("token malformed")
+	ErrTokenInvalid   = errors.New("token invalid")
+	ErrTokenRevoked   = errors.New("token revoked")
+)
 
 type User struct {
-	Id int64 `json:"id"`
+	Id       int64  `json:"id"`
+	DeviceId string `json:"deviceId,omitempty"` // 登录设备id，多端登录策略按此区分会话
 }
 
 type UserClaims struct {
@@ -17,52 +36,150 @@ type UserClaims struct {
 	jwt.RegisteredClaims
 }
 
-func Intercept(ce cache.Provider, authHeader string) *UserClaims {
-	claims := func(authHeader string) *UserClaims {
-		if authHeader == "" {
-			return nil
-		}
-		// Bearer <token>
-		var tokenString string
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			tokenString = authHeader[7:]
-		} else {
-			return nil
-		}
-		// 解析 Token
-		token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected signing method")
-			}
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
-			return nil
-		}
-		// 提取 claims
-		claims, ok := token.Claims.(*UserClaims)
-		if !ok {
-			return nil
-		}
+type tokenEntry struct {
+	jti      string
+	expireAt int64
+}
 
-		if claims.User.Id == 0 {
-			return nil
-		}
+// Authenticator 用户认证器，校验 jwt 并通过 redis 判断 token 是否已被吊销
+type Authenticator struct {
+	ce  cache.Provider
+	ttl time.Duration
 
-		// todo 校验 jwt-id 跟 redis 保存的key是否对得上
-		//if claims.ID != ce.String(context.Background(), dto.GetUserTokenKey(claims.User.Id)) {
-		//	return nil
-		//}
-		return claims
-	}(authHeader)
+	// 校验密钥，配置变更时整体替换
+	keys atomic.Pointer[keyring]
 
-	if claims == nil {
-		return nil
+	reloadMu sync.Mutex
+	cfg      dto.JwtConfig
+	jwksMod  time.Time // JWKS 文件最后修改时间
+
+	mu     sync.Mutex
+	tokens map[int64]tokenEntry // uid -> redis 中保存的 jwt-id
+}
+
+func NewAuthenticator(ce cache.Provider) *Authenticator {
+	return &Authenticator{
+		ce:     ce,
+		ttl:    tokenCacheTTL,
+		tokens: make(map[int64]tokenEntry),
 	}
+}
 
-	if claims.User.Id == 0 {
+// Reload 重新加载校验密钥，失败时保留原有密钥
+func (a *Authenticator) Reload(cfg dto.JwtConfig) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	return a.load(cfg)
+}
+
+// Refresh JWKS 文件有变化时重新加载
+func (a *Authenticator) Refresh() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	if a.cfg.JwksFile == "" {
 		return nil
 	}
+	fi, err := os.Stat(a.cfg.JwksFile)
+	if err != nil || fi.ModTime().Equal(a.jwksMod) {
+		return err
+	}
+	return a.load(a.cfg)
+}
+
+func (a *Authenticator) load(cfg dto.JwtConfig) error {
+	var mod time.Time
+	if cfg.JwksFile != "" {
+		fi, err := os.Stat(cfg.JwksFile)
+		if err != nil {
+			return err
+		}
+		mod = fi.ModTime()
+	}
+	kr, err := newKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	a.keys.Store(kr)
+	a.cfg = cfg
+	a.jwksMod = mod
+	return nil
+}
+
+// Intercept 校验 `Bearer <token>`，失败时返回具体原因
+func (a *Authenticator) Intercept(authHeader string) (*UserClaims, error) {
+	if authHeader == "" {
+		return nil, ErrTokenMissing
+	}
+	// Bearer <token>
+	if len(authHeader) <= 7 || authHeader[:7] != "Bearer " {
+		return nil, ErrTokenMalformed
+	}
+	tokenString := authHeader[7:]
+
+	kr := a.keys.Load()
+	if kr == nil {
+		return nil, ErrTokenInvalid
+	}
+	// 解析 Token
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, kr.keyfunc, jwt.WithValidMethods(kr.algs))
+	if err != nil || !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+	// 提取 claims
+	claims, ok := token.Claims.(*UserClaims)
+	if !ok || claims.User.Id == 0 {
+		return nil, ErrTokenInvalid
+	}
+
+	// 校验 jwt-id 跟 redis 保存的 key 是否对得上，登出、改密、封禁会删除或替换该 key
+	if !a.Active(claims.User.Id, claims.ID) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
 
-	return claims
+// Active 判断 jwt-id 是否仍是用户当前有效的 token
+//
+// 只信任与 jti 一致的缓存，不一致时重新读取 redis，避免重新登录后的新 token 被旧缓存拒绝。
+func (a *Authenticator) Active(uid int64, jti string) bool {
+	if jti == "" {
+		return false
+	}
+	if a.cachedId(uid) == jti {
+		return true
+	}
+	return a.loadId(uid) == jti
+}
+
+// cachedId 返回本地缓存的 jwt-id，未缓存或已过期时返回空
+func (a *Authenticator) cachedId(uid int64) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.tokens[uid]
+	if !ok || entry.expireAt <= time.Now().UnixNano() {
+		return ""
+	}
+	return entry.jti
+}
+
+// loadId 从 redis 读取用户当前有效的 jwt-id 并缓存，未找到时不缓存
+func (a *Authenticator) loadId(uid int64) string {
+	jti := a.ce.String(context.Background(), dto.GetUserTokenKey(uid))
+	now := time.Now().UnixNano()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.tokens) >= tokenCacheSweep {
+		for k, v := range a.tokens {
+			if v.expireAt <= now {
+				delete(a.tokens, k)
+			}
+		}
+	}
+	if jti == "" {
+		delete(a.tokens, uid)
+		return ""
+	}
+	a.tokens[uid] = tokenEntry{jti: jti, expireAt: now + int64(a.ttl)}
+	return jti
 }
@@ -0,0 +1,176 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/cache"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// memCache 内存版 cache.Provider，仅实现认证用到的方法
+type memCache struct {
+	cache.Provider
+
+	mu    sync.Mutex
+	data  map[string]string
+	reads int
+}
+
+func newMemCache() *memCache {
+	return &memCache{data: make(map[string]string)}
+}
+
+func (m *memCache) String(_ context.Context, key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reads++
+	return m.data[key]
+}
+
+func (m *memCache) Set(_ context.Context, key, value string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return true
+}
+
+func (m *memCache) Delete(_ context.Context, key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return true
+}
+
+var testSecret = []byte("gateway-test-secret")
+
+func newTestAuthenticator(t *testing.T, ce cache.Provider) *Authenticator {
+	t.Helper()
+	auth := NewAuthenticator(ce)
+	if err := auth.Reload(dto.JwtConfig{Keys: []dto.JwtKey{{Kid: "test", Secret: string(testSecret)}}}); err != nil {
+		t.Fatalf("reload keys: %v", err)
+	}
+	return auth
+}
+
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, uid int64, jti string) string {
+	t.Helper()
+	claims := UserClaims{
+		User: User{Id: uid},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       jti,
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return "Bearer " + signed
+}
+
+func signToken(t *testing.T, uid int64, jti string) string {
+	return signWith(t, jwt.SigningMethodHS256, "test", testSecret, uid, jti)
+}
+
+func TestInterceptRevocation(t *testing.T) {
+	ce := newMemCache()
+	ce.Set(context.Background(), dto.GetUserTokenKey(10001), "jti-1")
+	auth := newTestAuthenticator(t, ce)
+
+	claims, err := auth.Intercept(signToken(t, 10001, "jti-1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claims.User.Id != 10001 {
+		t.Fatalf("unexpected uid: got %d, want %d", claims.User.Id, 10001)
+	}
+
+	if _, err = auth.Intercept(signToken(t, 10001, "jti-0")); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("stale jti: got %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err = auth.Intercept(signToken(t, 10001, "")); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("empty jti: got %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err = auth.Intercept(signToken(t, 10002, "jti-1")); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("missing key: got %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestInterceptLocalCache(t *testing.T) {
+	ce := newMemCache()
+	ce.Set(context.Background(), dto.GetUserTokenKey(10001), "jti-1")
+	auth := newTestAuthenticator(t, ce)
+	auth.ttl = 50 * time.Millisecond
+	token := signToken(t, 10001, "jti-1")
+
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Intercept(token); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if ce.reads != 1 {
+		t.Fatalf("unexpected redis reads: got %d, want %d", ce.reads, 1)
+	}
+
+	// 登出后本地缓存过期即生效
+	ce.Delete(context.Background(), dto.GetUserTokenKey(10001))
+	time.Sleep(60 * time.Millisecond)
+	if _, err := auth.Intercept(token); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("after logout: got %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestInterceptRotatedToken(t *testing.T) {
+	ce := newMemCache()
+	ce.Set(context.Background(), dto.GetUserTokenKey(10001), "jti-1")
+	auth := newTestAuthenticator(t, ce)
+	if _, err := auth.Intercept(signToken(t, 10001, "jti-1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 重新登录更换 jti 后新 token 立即可用，旧 token 被拒绝
+	ce.Set(context.Background(), dto.GetUserTokenKey(10001), "jti-2")
+	if _, err := auth.Intercept(signToken(t, 10001, "jti-2")); err != nil {
+		t.Fatalf("rotated token: %v", err)
+	}
+	if _, err := auth.Intercept(signToken(t, 10001, "jti-1")); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("old token: got %v, want %v", err, ErrTokenRevoked)
+	}
+
+	// 未找到的 key 不缓存，登录后立即可用
+	if _, err := auth.Intercept(signToken(t, 10002, "jti-3")); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("missing key: got %v, want %v", err, ErrTokenRevoked)
+	}
+	ce.Set(context.Background(), dto.GetUserTokenKey(10002), "jti-3")
+	if _, err := auth.Intercept(signToken(t, 10002, "jti-3")); err != nil {
+		t.Fatalf("after login: %v", err)
+	}
+}
+
+func TestInterceptMalformed(t *testing.T) {
+	auth := newTestAuthenticator(t, newMemCache())
+
+	cases := []struct {
+		header string
+		want   error
+	}{
+		{"", ErrTokenMissing},
+		{"Token abc", ErrTokenMalformed},
+		{"Bearer ", ErrTokenMalformed},
+		{"Bearer not-a-jwt", ErrTokenInvalid},
+	}
+	for _, c := range cases {
+		if _, err := auth.Intercept(c.header); !errors.Is(err, c.want) {
+			t.Fatalf("header %q: got %v, want %v", c.header, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"errors"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/panjf2000/gnet/v2"
+)
+
+// CloseReason 连接关闭原因，用于关闭帧、日志与统计
+type CloseReason uint32
+
+const (
+	CloseNone             CloseReason = iota
+	CloseClient                       // 客户端发送关闭帧
+	CloseHandshakeFailed              // 升级失败，未发送关闭帧
+	CloseIpDenied                     // IP 被禁止
+	CloseRateLimited                  // 超出限流
+	CloseProtocolError                // 帧格式错误
+	CloseInvalidPayload               // 文本消息不是合法的 UTF-8
+	CloseMessageTooBig                // 消息解压后过大
+	CloseBadRequest                   // 认证或重连请求格式错误
+	CloseUnauthenticated              // 认证失败或未认证发送消息
+	CloseAuthTimeout                  // 未在规定时间内认证
+	CloseHeartbeatTimeout             // 心跳超时
+	CloseKicked                       // 在其他设备登录或超出会话上限
+	CloseLoginConflict                // reject 登录策略下已有会话
+	CloseIdleTimeout                  // 长时间未发送业务消息
+	CloseSlowConsumer                 // 发送队列已满，disconnect 策略下断开
+)
+
+type closeInfo struct {
+	name   string
+	status ws.StatusCode
+}
+
+// 4000-4999 为应用自定义状态码
+var closeInfos = [...]closeInfo{
+	CloseNone:             {"none", 0},
+	CloseClient:           {"client", ws.StatusNormalClosure},
+	CloseHandshakeFailed:  {"handshake_failed", 0},
+	CloseIpDenied:         {"ip_denied", ws.StatusPolicyViolation},
+	CloseRateLimited:      {"rate_limited", 4006},
+	CloseProtocolError:    {"protocol_error", ws.StatusProtocolError},
+	CloseInvalidPayload:   {"invalid_payload", ws.StatusInvalidFramePayloadData},
+	CloseMessageTooBig:    {"message_too_big", ws.StatusMessageTooBig},
+	CloseBadRequest:       {"bad_request", 4000},
+	CloseUnauthenticated:  {"unauthenticated", 4001},
+	CloseAuthTimeout:      {"auth_timeout", 4002},
+	CloseHeartbeatTimeout: {"heartbeat_timeout", 4003},
+	CloseKicked:           {"kicked", 4004},
+	CloseLoginConflict:    {"login_conflict", 4005},
+	CloseIdleTimeout:      {"idle_timeout", 4007},
+	CloseSlowConsumer:     {"slow_consumer", 4008},
+}
+
+// closeFrames 预先编码的关闭帧，状态码为 0 的原因不发送关闭帧
+var closeFrames = func() [][]byte {
+	frames := make([][]byte, len(closeInfos))
+	for r, info := range closeInfos {
+		if info.status != 0 {
+			frames[r] = ws.MustCompileFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(info.status, info.name)))
+		}
+	}
+	return frames
+}()
+
+func (r CloseReason) String() string {
+	if int(r) < len(closeInfos) {
+		return closeInfos[r].name
+	}
+	return "unknown"
+}
+
+// Status 关闭帧中的状态码
+func (r CloseReason) Status() ws.StatusCode {
+	if int(r) < len(closeInfos) {
+		return closeInfos[r].status
+	}
+	return 0
+}
+
+// decodeCloseReason 按解码错误选择关闭原因
+func decodeCloseReason(err error) CloseReason {
+	switch {
+	case errors.Is(err, ErrInflateTooLarge):
+		return CloseMessageTooBig
+	case errors.Is(err, wsutil.ErrInvalidUTF8):
+		return CloseInvalidPayload
+	default:
+		return CloseProtocolError
+	}
+}
+
+// closeFrame 记录关闭原因，返回需要发送的关闭帧；连接已在关闭中或未升级时返回 nil
+func closeFrame(c gnet.Conn, reason CloseReason) []byte {
+	wsc, ok := c.Context().(*wsCodec)
+	if !ok || !wsc.closeReason.CompareAndSwap(uint32(CloseNone), uint32(reason)) || !wsc.upgraded.Load() {
+		return nil
+	}
+	return closeFrames[reason]
+}
+
+// closeConn 在事件循环中发送关闭帧，返回 gnet.Close 由 gnet 写完缓冲区后关闭连接
+func closeConn(c gnet.Conn, reason CloseReason) gnet.Action {
+	if frame := closeFrame(c, reason); frame != nil {
+		if _, err := c.Write(frame); err != nil {
+			logger.Log.Error(err)
+		}
+	}
+	return gnet.Close
+}
+
+// asyncClose 在事件循环外依次发送 msgs 与关闭帧后关闭连接
+func asyncClose(c gnet.Conn, reason CloseReason, msgs ...[]byte) {
+	if frame := closeFrame(c, reason); frame != nil {
+		msgs = append(msgs, frame)
+	}
+	if len(msgs) == 0 {
+		_ = c.Close()
+		return
+	}
+	err := c.AsyncWritev(msgs, func(c gnet.Conn, err error) error {
+		return c.Close()
+	})
+	if err != nil {
+		_ = c.Close()
+	}
+}
+
+// ackClose 回复客户端的关闭帧
+func (w *wsCodec) ackClose(c gnet.Conn) gnet.Action {
+	w.closeReason.CompareAndSwap(uint32(CloseNone), uint32(CloseClient))
+	if err := wsutil.HandleClientControlMessage(c, *w.peerClose); err != nil {
+		var closed wsutil.ClosedError
+		if errors.As(err, &closed) {
+			logger.Log.Infof("conn[%v] closed by client, status %d reason %q", c.RemoteAddr().String(), closed.Code, closed.Reason)
+		}
+	}
+	return gnet.Close
+}
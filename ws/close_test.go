@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/gorilla/websocket"
+)
+
+func TestCloseReason(t *testing.T) {
+	if CloseHeartbeatTimeout.String() != "heartbeat_timeout" || CloseHeartbeatTimeout.Status() != 4003 {
+		t.Fatalf("unexpected reason %s %d", CloseHeartbeatTimeout, CloseHeartbeatTimeout.Status())
+	}
+	if CloseReason(255).String() != "unknown" {
+		t.Fatal("unknown reason")
+	}
+	for r := range closeInfos {
+		if closeInfos[r].name == "" {
+			t.Fatalf("reason %d has no name", r)
+		}
+	}
+	if closeFrames[CloseHandshakeFailed] != nil {
+		t.Fatal("handshake failure should not send a close frame")
+	}
+}
+
+func TestCloseFrameFromServer(t *testing.T) {
+	url := startEchoServer(t, dto.CompressionConfig{})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, got, err := conn.ReadMessage(); err != nil || string(got) != "hello" {
+		t.Fatalf("echo: %q %v", got, err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, int(CloseUnauthenticated.Status())) {
+		t.Fatalf("expected close %d, got %v", CloseUnauthenticated.Status(), err)
+	}
+	if ce := err.(*websocket.CloseError); ce.Text != CloseUnauthenticated.String() {
+		t.Fatalf("unexpected close text %q", ce.Text)
+	}
+}
+
+func TestCloseFrameFromClient(t *testing.T) {
+	url := startEchoServer(t, dto.CompressionConfig{})
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 关闭帧之前的消息照常处理
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("last")); err != nil {
+		t.Fatal(err)
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "bye")
+	if err = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, got, err := conn.ReadMessage(); err != nil || string(got) != "last" {
+		t.Fatalf("echo: %q %v", got, err)
+	}
+	if _, _, err = conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected echoed close, got %v", err)
+	}
+}
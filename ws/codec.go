@@ -2,8 +2,12 @@ package ws
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"github.com/aluka-7/game-gateway/conn"
 	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/aluka-7/game-gateway/utils/timewheel"
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
 	"github.com/panjf2000/gnet/v2"
@@ -17,16 +21,42 @@ type wsCodec struct {
 	sync.RWMutex
 	data        map[string]interface{} // session data store
 	uid         int64
-	upgraded    bool         // 链接是否升级
-	buf         bytes.Buffer // 从实际socket中读取到的数据缓存
-	wsMsgBuf    wsMessageBuf // ws 消息缓存
-	ConnectTime int64        // 连接时间
+	client      *conn.Client     // 认证后绑定的会话
+	resumeToken string           // 最近签发的重连凭证
+	claims      *UserClaims      // 握手阶段认证通过的用户
+	limiter     *connLimiter     // 上行消息限流
+	ip          string           // 计入单 IP 连接数的对端 IP
+	deflate     *connDeflate     // 协商的 permessage-deflate，未协商时为 nil
+	upgraded    atomic.Bool      // 链接是否升级
+	closeReason atomic.Uint32    // 关闭原因 CloseReason，首次记录的原因生效
+	peerClose   *wsutil.Message  // 客户端发送的关闭帧，处理完之前的消息后回复
+	buf         bytes.Buffer     // 从实际socket中读取到的数据缓存
+	wsMsgBuf    wsMessageBuf     // ws 消息缓存
+	ConnectTime int64            // 连接时间
+	timer       *timewheel.Timer // 超时定时器
+	closed      atomic.Bool      // 连接已关闭，定时器不再检查
+	lastSeen    atomic.Int64     // 最后收到任意帧的时间，纳秒
+	lastActive  atomic.Int64     // 最后收到业务消息的时间，纳秒
+	lastPing    int64            // 最后发送 ping 的时间，只在时间轮中访问
+	bound       []string         // 会话绑定过的游戏服务，只在事件循环中访问
 }
 
 func NewWsCodec() *wsCodec {
-	return &wsCodec{
+	now := time.Now()
+	w := &wsCodec{
 		data:        make(map[string]interface{}),
-		ConnectTime: time.Now().Unix(),
+		ConnectTime: now.Unix(),
+	}
+	w.lastSeen.Store(now.UnixNano())
+	w.lastActive.Store(now.UnixNano())
+	return w
+}
+
+// touch 收到任意帧后刷新存活时间
+func (w *wsCodec) touch() {
+	w.lastSeen.Store(time.Now().UnixNano())
+	if w.client != nil {
+		w.client.Touch()
 	}
 }
 
@@ -53,6 +83,20 @@ func (w *wsCodec) UID() int64 {
 	return atomic.LoadInt64(&w.uid)
 }
 
+// SessionId 返回网关会话id，未认证时为空
+func (w *wsCodec) SessionId() string {
+	if w.client == nil {
+		return ""
+	}
+	return w.client.SessionId
+}
+
+func newSessionId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // Set associates value with the key in session storage
 func (w *wsCodec) Set(key string, value interface{}) {
 	w.Lock()
@@ -94,8 +138,9 @@ func (w *wsCodec) String(key string) string {
 	return value
 }
 
-func (w *wsCodec) upgrade(c gnet.Conn) (ok bool, action gnet.Action) {
-	if w.upgraded {
+// upgrade 处理 http 升级请求，数据不完整时等待下一次读取，newUpgrader 每次尝试都会重新构建
+func (w *wsCodec) upgrade(c gnet.Conn, newUpgrader func() ws.Upgrader) (ok bool, action gnet.Action) {
+	if w.upgraded.Load() {
 		ok = true
 		return
 	}
@@ -104,7 +149,8 @@ func (w *wsCodec) upgrade(c gnet.Conn) (ok bool, action gnet.Action) {
 	tmpReader := bytes.NewReader(buf.Bytes())
 	oldLen := tmpReader.Len()
 
-	hs, err := ws.Upgrade(readWrite{tmpReader, c})
+	u := newUpgrader()
+	hs, err := u.Upgrade(readWrite{tmpReader, c})
 	skipN := oldLen - tmpReader.Len()
 	if err != nil {
 		if err == io.EOF || err == io.ErrUnexpectedEOF { //数据不完整
@@ -116,9 +162,12 @@ func (w *wsCodec) upgrade(c gnet.Conn) (ok bool, action gnet.Action) {
 		return
 	}
 	buf.Next(skipN)
-	logger.Log.Infof("conn[%v] upgrade websocket protocol! Handshake: %v", c.RemoteAddr().String(), hs)
+	logger.Log.Infof("conn[%v] upgrade websocket protocol! Extensions: %v", c.RemoteAddr().String(), hs.Extensions)
+	if len(hs.Extensions) == 0 {
+		w.deflate = nil
+	}
 	ok = true
-	w.upgraded = true
+	w.upgraded.Store(true)
 	return
 }
 
@@ -149,6 +198,11 @@ func (w *wsCodec) Decode(c gnet.Conn) (outs []wsutil.Message, err error) {
 		return
 	}
 	for _, message := range messages {
+		if message.OpCode == ws.OpClose {
+			// 之后的消息不再处理
+			w.peerClose = &message
+			return
+		}
 		if message.OpCode.IsControl() {
 			err = wsutil.HandleClientControlMessage(c, message)
 			if err != nil {
@@ -193,6 +247,8 @@ func (w *wsCodec) readWsMessages() (messages []wsutil.Message, err error) {
 			}
 
 			msgBuf.curHeader = &head
+			// 任意帧（包括控制帧与分片）都视为存活
+			w.touch()
 			err = ws.WriteHeader(&msgBuf.cachedBuf, head)
 			if err != nil {
 				return nil, err
@@ -211,7 +267,7 @@ func (w *wsCodec) readWsMessages() (messages []wsutil.Message, err error) {
 			}
 		}
 		if msgBuf.curHeader.Fin { //当前 header 已经是一个完整消息
-			messages, err = wsutil.ReadClientMessage(&msgBuf.cachedBuf, messages)
+			messages, err = w.readClientMessage(&msgBuf.cachedBuf, messages)
 			if err != nil {
 				return nil, err
 			}
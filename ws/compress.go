@@ -0,0 +1,252 @@
+package ws
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/panjf2000/gnet/v2"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	defaultCompressLevel     = flate.BestSpeed
+	defaultCompressThreshold = 256
+	defaultMaxInflate        = 1 << 20
+	// deflateWindow 客户端复用压缩上下文时保留的滑动窗口大小
+	deflateWindow = 32 << 10
+)
+
+var (
+	// deflateTail 压缩数据末尾被省略的空块，见 RFC 7692 7.2.1
+	deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+	// deflateFinal 解压时追加的结束块，避免读取到 io.ErrUnexpectedEOF
+	deflateFinal = []byte{0x01, 0x00, 0x00, 0xff, 0xff}
+
+	ErrInflateTooLarge = errors.New("inflated message too large")
+
+	inflaters sync.Pool
+)
+
+// compressPolicy permessage-deflate 配置，配置变更时整体替换，已建立的连接沿用协商时的配置
+//
+// 服务端始终声明 server_no_context_takeover，同一份下行消息对所有连接的压缩结果相同，广播时只需压缩一次。
+type compressPolicy struct {
+	level                 int
+	threshold             int
+	maxInflate            int
+	clientContextTakeover bool
+	writers               sync.Pool
+}
+
+func newCompressPolicy(cfg dto.CompressionConfig) *compressPolicy {
+	if !cfg.Enable {
+		return nil
+	}
+	p := &compressPolicy{
+		level:                 cfg.Level,
+		threshold:             cfg.Threshold,
+		maxInflate:            cfg.MaxInflate,
+		clientContextTakeover: cfg.ClientContextTakeover,
+	}
+	if p.level < flate.BestSpeed || p.level > flate.BestCompression {
+		p.level = defaultCompressLevel
+	}
+	if p.threshold <= 0 {
+		p.threshold = defaultCompressThreshold
+	}
+	if p.maxInflate <= 0 {
+		p.maxInflate = defaultMaxInflate
+	}
+	return p
+}
+
+// extension 本次握手使用的协商器，未开启压缩时返回 nil
+func (p *compressPolicy) extension() *wsflate.Extension {
+	if p == nil {
+		return nil
+	}
+	return &wsflate.Extension{Parameters: wsflate.Parameters{
+		ServerNoContextTakeover: true,
+		ClientNoContextTakeover: !p.clientContextTakeover,
+	}}
+}
+
+// negotiate 忽略无法解析的压缩参数，按不支持压缩处理
+func negotiate(ext *wsflate.Extension) func(httphead.Option) (httphead.Option, error) {
+	return func(opt httphead.Option) (httphead.Option, error) {
+		accept, err := ext.Negotiate(opt)
+		if err != nil {
+			return httphead.Option{}, nil
+		}
+		return accept, nil
+	}
+}
+
+// compress 压缩单条消息，去掉末尾的空块
+func (p *compressPolicy) compress(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, _ := p.writers.Get().(*flate.Writer)
+	if fw == nil {
+		var err error
+		if fw, err = flate.NewWriter(&buf, p.level); err != nil {
+			return nil, err
+		}
+	} else {
+		fw.Reset(&buf)
+	}
+	defer p.writers.Put(fw)
+	if _, err := fw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// connDeflate 单连接协商后的压缩状态
+type connDeflate struct {
+	policy          *compressPolicy
+	contextTakeover bool   // 客户端跨消息复用压缩上下文
+	window          []byte // 最近解压的数据，作为下一条消息的字典
+}
+
+func newConnDeflate(p *compressPolicy, params wsflate.Parameters) *connDeflate {
+	return &connDeflate{
+		policy:          p,
+		contextTakeover: p.clientContextTakeover && !params.ClientNoContextTakeover,
+	}
+}
+
+// inflate 解压单条上行消息
+func (d *connDeflate) inflate(p []byte) ([]byte, error) {
+	src := io.MultiReader(bytes.NewReader(p), bytes.NewReader(deflateTail), bytes.NewReader(deflateFinal))
+	fr, _ := inflaters.Get().(io.ReadCloser)
+	if fr == nil {
+		fr = flate.NewReaderDict(src, d.window)
+	} else if err := fr.(flate.Resetter).Reset(src, d.window); err != nil {
+		return nil, err
+	}
+	defer inflaters.Put(fr)
+
+	var out bytes.Buffer
+	n, err := out.ReadFrom(io.LimitReader(fr, int64(d.policy.maxInflate)+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > int64(d.policy.maxInflate) {
+		return nil, ErrInflateTooLarge
+	}
+	if d.contextTakeover {
+		d.window = append(d.window, out.Bytes()...)
+		if len(d.window) > deflateWindow {
+			d.window = append(d.window[:0:0], d.window[len(d.window)-deflateWindow:]...)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// readClientMessage 读取一条完整的客户端消息，协商压缩后解压 RSV1 置位的消息
+func (w *wsCodec) readClientMessage(r io.Reader, m []wsutil.Message) ([]wsutil.Message, error) {
+	if w.deflate == nil {
+		return wsutil.ReadClientMessage(r, m)
+	}
+	var state wsflate.MessageState
+	rd := wsutil.Reader{
+		Source:     r,
+		State:      ws.StateServerSide | ws.StateExtended,
+		Extensions: []wsutil.RecvExtension{&state},
+		OnIntermediate: func(hdr ws.Header, src io.Reader) error {
+			bts, err := io.ReadAll(src)
+			if err != nil {
+				return err
+			}
+			m = append(m, wsutil.Message{OpCode: hdr.OpCode, Payload: bts})
+			return nil
+		},
+	}
+	h, err := rd.NextFrame()
+	if err != nil {
+		return m, err
+	}
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(&rd); err != nil {
+		return m, err
+	}
+	p := buf.Bytes()
+	if state.IsCompressed() {
+		if p, err = w.deflate.inflate(p); err != nil {
+			return m, err
+		}
+	}
+	// 压缩消息只能在解压后校验 UTF-8
+	if h.OpCode == ws.OpText && !utf8.Valid(p) {
+		return m, wsutil.ErrInvalidUTF8
+	}
+	return append(m, wsutil.Message{OpCode: h.OpCode, Payload: p}), nil
+}
+
+// outFrame 一条下行消息编码后的帧，按连接的压缩协商结果选择，多个连接共享编码结果
+type outFrame struct {
+	payload  []byte
+	plain    []byte
+	policy   *compressPolicy
+	deflated []byte
+}
+
+func newOutFrame(payload []byte) *outFrame {
+	return &outFrame{payload: payload}
+}
+
+// For 返回发送给 wsc 的帧
+func (f *outFrame) For(wsc *wsCodec) ([]byte, error) {
+	if d := wsc.deflate; d != nil && len(f.payload) >= d.policy.threshold {
+		if f.policy == d.policy {
+			return f.deflated, nil
+		}
+		compressed, err := d.policy.compress(f.payload)
+		if err != nil {
+			return nil, err
+		}
+		frame := ws.NewBinaryFrame(compressed)
+		frame.Header.Rsv = ws.Rsv(true, false, false)
+		deflated, err := ws.CompileFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		// 缓存第一种压缩配置的结果，配置变更前后的连接混合时其余连接单独压缩
+		if f.policy == nil {
+			f.policy, f.deflated = d.policy, deflated
+		}
+		return deflated, nil
+	}
+	if f.plain == nil {
+		plain, err := ws.CompileFrame(ws.NewBinaryFrame(f.payload))
+		if err != nil {
+			return nil, err
+		}
+		f.plain = plain
+	}
+	return f.plain, nil
+}
+
+// writeFrame 编码并写入一条下行消息
+func writeFrame(c gnet.Conn, f *outFrame) error {
+	wsc, ok := c.Context().(*wsCodec)
+	if !ok {
+		return nil
+	}
+	frame, err := f.For(wsc)
+	if err != nil {
+		return err
+	}
+	_, err = c.Write(frame)
+	return err
+}
@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"bytes"
+	"compress/flate"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/gobwas/ws"
+	"github.com/gorilla/websocket"
+)
+
+func TestCompressionGorilla(t *testing.T) {
+	url := startEchoServer(t, dto.CompressionConfig{Enable: true, Threshold: 64})
+
+	dialer := websocket.Dialer{EnableCompression: true, HandshakeTimeout: 3 * time.Second}
+	conn, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("compression not negotiated: %q", ext)
+	}
+
+	big := `{"server":"wingo","event":"state","data":"` + strings.Repeat("verbose json payload ", 200) + `"}`
+	for _, msg := range []string{big, `{"server":"system","event":"ping"}`, big} {
+		if err = conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		_, got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Fatalf("echo mismatch: got %d bytes, want %d", len(got), len(msg))
+		}
+	}
+}
+
+func TestCompressionDisabled(t *testing.T) {
+	url := startEchoServer(t, dto.CompressionConfig{})
+
+	dialer := websocket.Dialer{EnableCompression: true, HandshakeTimeout: 3 * time.Second}
+	conn, resp, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Fatalf("unexpected extensions: %q", ext)
+	}
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, got, err := conn.ReadMessage(); err != nil || string(got) != "hello" {
+		t.Fatalf("echo: %q %v", got, err)
+	}
+}
+
+func TestOutFrameThreshold(t *testing.T) {
+	p := newCompressPolicy(dto.CompressionConfig{Enable: true, Threshold: 16})
+	wsc := NewWsCodec()
+	wsc.deflate = &connDeflate{policy: p}
+
+	small, err := newOutFrame([]byte("tiny")).For(wsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small[0]&0x40 != 0 {
+		t.Fatal("message below threshold should not be compressed")
+	}
+
+	payload := bytes.Repeat([]byte("abcdefgh"), 64)
+	f := newOutFrame(payload)
+	frame, err := f.For(wsc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := ws.ReadHeader(bytes.NewReader(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r1, _, _ := ws.RsvBits(h.Rsv); !r1 || int(h.Length) >= len(payload) {
+		t.Fatalf("message not compressed: rsv=%v length=%d", h.Rsv, h.Length)
+	}
+	// 同一配置的连接共享压缩结果
+	other := NewWsCodec()
+	other.deflate = &connDeflate{policy: p}
+	if again, _ := f.For(other); &again[0] != &frame[0] {
+		t.Fatal("compressed frame not shared")
+	}
+	got, err := (&connDeflate{policy: p}).inflate(frame[len(frame)-int(h.Length):])
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+}
+
+func TestInflateContextTakeover(t *testing.T) {
+	p := newCompressPolicy(dto.CompressionConfig{Enable: true, ClientContextTakeover: true})
+	d := &connDeflate{policy: p, contextTakeover: true}
+
+	// 模拟复用压缩上下文的客户端
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	msg := []byte(strings.Repeat("context takeover ", 50))
+	for i := 0; i < 3; i++ {
+		buf.Reset()
+		_, _ = fw.Write(msg)
+		_ = fw.Flush()
+		got, err := d.inflate(bytes.TrimSuffix(buf.Bytes(), deflateTail))
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+}
+
+func TestInflateLimit(t *testing.T) {
+	p := newCompressPolicy(dto.CompressionConfig{Enable: true, MaxInflate: 1024})
+	compressed, err := p.compress(make([]byte, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (&connDeflate{policy: p}).inflate(compressed); err != ErrInflateTooLarge {
+		t.Fatalf("expected ErrInflateTooLarge, got %v", err)
+	}
+}
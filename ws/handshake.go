@@ -0,0 +1,198 @@
+package ws
+
+import (
+	"bytes"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/gobwas/ws"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	// protocolTokenPrefix 通过 Sec-WebSocket-Protocol 传递 token 时的前缀，如 `bearer.<jwt>`
+	protocolTokenPrefix = "bearer."
+)
+
+// 会话中保存握手信息的 key
+const (
+	SessionProtocol = "protocol" // 选定的子协议
+)
+
+var (
+	headerAuthorization = []byte("Authorization")
+	headerOrigin        = []byte("Origin")
+
+	// defaultCaptureHeaders 未配置 captureHeaders 时保存到会话中的请求头
+	defaultCaptureHeaders = []string{"User-Agent", "X-Client-Version"}
+
+	// bearerChallenge 认证失败时的 401 响应头
+	bearerChallenge = ws.HandshakeHeaderString("WWW-Authenticate: Bearer\r\n")
+)
+
+// handshakePolicy 握手校验规则，配置变更时整体替换
+type handshakePolicy struct {
+	origins         originMatcher
+	protocols       map[string]bool
+	requireProtocol bool
+	capture         map[string]string // 小写请求头 -> 会话 key
+}
+
+func newHandshakePolicy(cfg dto.HandshakeConfig) *handshakePolicy {
+	p := &handshakePolicy{
+		origins:         newOriginMatcher(cfg.Origins),
+		requireProtocol: cfg.RequireProtocol && len(cfg.Protocols) > 0,
+		capture:         make(map[string]string),
+	}
+	if len(cfg.Protocols) > 0 {
+		p.protocols = make(map[string]bool, len(cfg.Protocols))
+		for _, name := range cfg.Protocols {
+			p.protocols[name] = true
+		}
+	}
+	headers := cfg.CaptureHeaders
+	if headers == nil {
+		headers = defaultCaptureHeaders
+	}
+	for _, h := range headers {
+		key := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(h))
+		p.capture[strings.ToLower(key)] = key
+	}
+	return p
+}
+
+// allowProtocol 未配置子协议白名单时接受任意子协议
+func (p *handshakePolicy) allowProtocol(name string) bool {
+	return p.protocols == nil || p.protocols[name]
+}
+
+// handshake 单次升级尝试中从 http 请求里收集的信息
+type handshake struct {
+	header   string            // Authorization 头
+	protocol string            // Sec-WebSocket-Protocol 中的 token
+	query    string            // token 查询参数
+	origin   string            // Origin 头
+	selected string            // 选定的子协议（不含 token）
+	headers  map[string]string // 需要保存到会话中的请求头
+}
+
+// token 按 Authorization 头、子协议、查询参数的顺序选取 token
+func (hs *handshake) token() string {
+	for _, t := range []string{hs.header, hs.protocol, hs.query} {
+		if t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+func reject(status int, reason string, options ...ws.RejectOption) error {
+	return ws.RejectConnectionError(append([]ws.RejectOption{
+		ws.RejectionStatus(status),
+		ws.RejectionReason(reason),
+	}, options...)...)
+}
+
+// newUpgrader 构建升级器
+//
+// 升级前依次校验 Origin（403）、子协议（400），请求中携带 token 时完成认证（401），
+// 通过后将选定的子协议及配置的请求头保存到会话中。
+func (w *Server) newUpgrader(wsc *wsCodec) func() ws.Upgrader {
+	return func() ws.Upgrader {
+		policy := w.handshake.Load()
+		compress := w.compress.Load()
+		ext := compress.extension()
+		hs := &handshake{}
+		u := ws.Upgrader{
+			OnRequest: func(uri []byte) error {
+				u, err := url.ParseRequestURI(string(uri))
+				if err != nil {
+					return reject(http.StatusBadRequest, "bad request uri")
+				}
+				if t := u.Query().Get("token"); t != "" {
+					hs.query = bearer(t)
+				}
+				return nil
+			},
+			OnHeader: func(key, value []byte) error {
+				switch {
+				case bytes.EqualFold(key, headerAuthorization):
+					hs.header = string(value)
+				case bytes.EqualFold(key, headerOrigin):
+					hs.origin = string(value)
+				}
+				if name, ok := policy.capture[strings.ToLower(string(key))]; ok {
+					if hs.headers == nil {
+						hs.headers = make(map[string]string, len(policy.capture))
+					}
+					hs.headers[name] = string(value)
+				}
+				return nil
+			},
+			ProtocolCustom: func(value []byte) (string, bool) {
+				var selected, token string
+				for _, p := range strings.Split(string(value), ",") {
+					p = strings.TrimSpace(p)
+					if p == "" {
+						continue
+					}
+					if len(p) > len(protocolTokenPrefix) && strings.EqualFold(p[:len(protocolTokenPrefix)], protocolTokenPrefix) {
+						if token == "" {
+							token = p
+							hs.protocol = bearer(p[len(protocolTokenPrefix):])
+						}
+						continue
+					}
+					if selected == "" && policy.allowProtocol(p) {
+						selected = p
+					}
+				}
+				hs.selected = selected
+				// 浏览器要求服务端回显一个客户端提供的子协议，只提供了 token 时回显 token 本身
+				if selected == "" {
+					selected = token
+				}
+				return selected, true
+			},
+			OnBeforeUpgrade: func() (ws.HandshakeHeader, error) {
+				if !policy.origins.Allowed(hs.origin) {
+					return nil, reject(http.StatusForbidden, "origin not allowed")
+				}
+				if policy.requireProtocol && hs.selected == "" {
+					return nil, reject(http.StatusBadRequest, "unsupported subprotocol")
+				}
+				if token := hs.token(); token != "" {
+					claims, err := w.auth.Intercept(token)
+					if err != nil {
+						return nil, reject(http.StatusUnauthorized, err.Error(), ws.RejectionHeader(bearerChallenge))
+					}
+					wsc.claims = claims
+				}
+				if hs.selected != "" {
+					wsc.Set(SessionProtocol, hs.selected)
+				}
+				for name, value := range hs.headers {
+					wsc.Set(name, value)
+				}
+				if ext != nil {
+					if params, ok := ext.Accepted(); ok {
+						wsc.deflate = newConnDeflate(compress, params)
+					}
+				}
+				return nil, nil
+			},
+		}
+		if ext != nil {
+			u.Negotiate = negotiate(ext)
+		}
+		return u
+	}
+}
+
+func bearer(token string) string {
+	if strings.HasPrefix(token, "Bearer ") {
+		return token
+	}
+	return "Bearer " + token
+}
@@ -0,0 +1,176 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aluka-7/game-gateway/dto"
+)
+
+// doHandshake 用给定的请求头执行一次升级，返回响应内容
+func doHandshake(t *testing.T, srv *Server, wsc *wsCodec, uri string, headers ...string) (string, error) {
+	t.Helper()
+	req := "GET " + uri + " HTTP/1.1\r\n" +
+		"Host: 127.0.0.1\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+	for _, h := range headers {
+		req += h + "\r\n"
+	}
+	req += "\r\n"
+	var out bytes.Buffer
+	_, err := srv.newUpgrader(wsc)().Upgrade(readWrite{strings.NewReader(req), &out})
+	return out.String(), err
+}
+
+func newHandshakeServer(t *testing.T) (*Server, string) {
+	ce := newMemCache()
+	ce.Set(context.Background(), dto.GetUserTokenKey(10001), "jti-1")
+	srv := &Server{auth: newTestAuthenticator(t, ce)}
+	srv.handshake.Store(newHandshakePolicy(dto.HandshakeConfig{}))
+	return srv, signToken(t, 10001, "jti-1")
+}
+
+func TestHandshakeAuth(t *testing.T) {
+	srv, token := newHandshakeServer(t)
+	jwt := strings.TrimPrefix(token, "Bearer ")
+
+	cases := []struct {
+		name     string
+		uri      string
+		headers  []string
+		protocol string
+	}{
+		{name: "header", uri: "/", headers: []string{"Authorization: " + token}},
+		{name: "query", uri: "/?token=" + jwt},
+		{name: "protocol", uri: "/", headers: []string{"Sec-WebSocket-Protocol: game.v1, bearer." + jwt}, protocol: "game.v1"},
+		{name: "protocol only", uri: "/", headers: []string{"Sec-WebSocket-Protocol: bearer." + jwt}, protocol: "bearer." + jwt},
+	}
+	for _, c := range cases {
+		wsc := NewWsCodec()
+		resp, err := doHandshake(t, srv, wsc, c.uri, c.headers...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.name, err)
+		}
+		if !strings.HasPrefix(resp, "HTTP/1.1 101") {
+			t.Fatalf("%s: unexpected response: %q", c.name, resp)
+		}
+		if wsc.claims == nil || wsc.claims.User.Id != 10001 {
+			t.Fatalf("%s: user not authenticated", c.name)
+		}
+		if c.protocol != "" && !strings.Contains(resp, "Sec-WebSocket-Protocol: "+c.protocol+"\r\n") {
+			t.Fatalf("%s: unexpected protocol in response: %q", c.name, resp)
+		}
+	}
+}
+
+func TestHandshakeReject(t *testing.T) {
+	srv, _ := newHandshakeServer(t)
+
+	wsc := NewWsCodec()
+	resp, err := doHandshake(t, srv, wsc, "/?token=bad")
+	if err == nil {
+		t.Fatal("expected error for bad token")
+	}
+	if !strings.HasPrefix(resp, "HTTP/1.1 401") || !strings.Contains(resp, "WWW-Authenticate: Bearer") {
+		t.Fatalf("unexpected response: %q", resp)
+	}
+	if wsc.claims != nil {
+		t.Fatal("claims set for rejected handshake")
+	}
+
+	// 不携带 token 时照常升级，由 system/auth 完成认证
+	wsc = NewWsCodec()
+	resp, err = doHandshake(t, srv, wsc, "/")
+	if err != nil || !strings.HasPrefix(resp, "HTTP/1.1 101") {
+		t.Fatalf("anonymous upgrade: err=%v resp=%q", err, resp)
+	}
+	if wsc.claims != nil {
+		t.Fatal("claims set for anonymous handshake")
+	}
+}
+
+func TestHandshakeOrigin(t *testing.T) {
+	srv, _ := newHandshakeServer(t)
+	srv.handshake.Store(newHandshakePolicy(dto.HandshakeConfig{
+		Origins: []string{"https://*.example.com", "http://localhost:3000"},
+	}))
+
+	cases := map[string]bool{
+		"":                         true, // 非浏览器客户端
+		"https://game.example.com": true,
+		"https://a.b.example.com":  true,
+		"http://game.example.com":  false,
+		"https://example.com":      false,
+		"https://evil-example.com": false,
+		"http://localhost:3000":    true,
+		"http://localhost:3001":    false,
+		"null":                     false,
+	}
+	for origin, allowed := range cases {
+		var headers []string
+		if origin != "" {
+			headers = append(headers, "Origin: "+origin)
+		}
+		resp, err := doHandshake(t, srv, NewWsCodec(), "/", headers...)
+		if allowed && (err != nil || !strings.HasPrefix(resp, "HTTP/1.1 101")) {
+			t.Fatalf("origin %q: expected upgrade, err=%v resp=%q", origin, err, resp)
+		}
+		if !allowed && (err == nil || !strings.HasPrefix(resp, "HTTP/1.1 403")) {
+			t.Fatalf("origin %q: expected 403, resp=%q", origin, resp)
+		}
+	}
+}
+
+func TestHandshakeProtocol(t *testing.T) {
+	srv, token := newHandshakeServer(t)
+	jwt := strings.TrimPrefix(token, "Bearer ")
+	srv.handshake.Store(newHandshakePolicy(dto.HandshakeConfig{
+		Protocols:       []string{"game.v2", "game.v1"},
+		RequireProtocol: true,
+	}))
+
+	wsc := NewWsCodec()
+	resp, err := doHandshake(t, srv, wsc, "/", "Sec-WebSocket-Protocol: chat, game.v1, bearer."+jwt)
+	if err != nil || !strings.Contains(resp, "Sec-WebSocket-Protocol: game.v1\r\n") {
+		t.Fatalf("unexpected response: err=%v resp=%q", err, resp)
+	}
+	if wsc.String(SessionProtocol) != "game.v1" || wsc.claims == nil {
+		t.Fatal("protocol or claims not saved")
+	}
+
+	for _, h := range [][]string{nil, {"Sec-WebSocket-Protocol: chat"}, {"Sec-WebSocket-Protocol: bearer." + jwt}} {
+		resp, err = doHandshake(t, srv, NewWsCodec(), "/", h...)
+		if err == nil || !strings.HasPrefix(resp, "HTTP/1.1 400") {
+			t.Fatalf("%v: expected 400, resp=%q", h, resp)
+		}
+	}
+}
+
+func TestHandshakeCaptureHeaders(t *testing.T) {
+	srv, _ := newHandshakeServer(t)
+
+	wsc := NewWsCodec()
+	_, err := doHandshake(t, srv, wsc, "/", "User-Agent: game-client/1.0", "x-client-version: 2.3.1", "X-Other: 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wsc.String("User-Agent") != "game-client/1.0" || wsc.String("X-Client-Version") != "2.3.1" {
+		t.Fatalf("headers not captured: %v", wsc.data)
+	}
+	if wsc.String("X-Other") != "" {
+		t.Fatal("unexpected header captured")
+	}
+
+	// 升级被拒绝时不保存
+	srv.handshake.Store(newHandshakePolicy(dto.HandshakeConfig{Origins: []string{"https://example.com"}}))
+	wsc = NewWsCodec()
+	_, _ = doHandshake(t, srv, wsc, "/", "Origin: https://evil.com", "User-Agent: bot")
+	if wsc.String("User-Agent") != "" {
+		t.Fatal("headers captured for rejected handshake")
+	}
+}
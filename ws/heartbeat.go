@@ -0,0 +1,118 @@
+package ws
+
+import (
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/gobwas/ws"
+	"github.com/panjf2000/gnet/v2"
+	"time"
+)
+
+const (
+	// wheelTick 时间轮刻度，超时误差不超过一个刻度
+	wheelTick = 100 * time.Millisecond
+
+	defaultHeartbeatTimeout = 30 * time.Second
+	defaultAuthTimeout      = 5 * time.Second
+	defaultPingInterval     = 10 * time.Second
+)
+
+// pingFrame 服务端发送的 websocket ping 帧
+var pingFrame = ws.MustCompileFrame(ws.NewPingFrame(nil))
+
+// heartbeatPolicy 连接超时配置，配置变更时整体替换，已有连接在下一次检查时生效
+type heartbeatPolicy struct {
+	timeout     time.Duration // 心跳超时
+	authTimeout time.Duration // 认证超时
+	idleTimeout time.Duration // 空闲超时，0 表示不限制
+	ping        time.Duration // 服务端 ping 间隔，0 表示不发送
+}
+
+func newHeartbeatPolicy(cfg dto.HeartbeatConfig) *heartbeatPolicy {
+	p := &heartbeatPolicy{
+		timeout:     time.Duration(cfg.Timeout) * time.Second,
+		authTimeout: time.Duration(cfg.AuthTimeout) * time.Second,
+		idleTimeout: time.Duration(cfg.IdleTimeout) * time.Second,
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultHeartbeatTimeout
+	}
+	if p.authTimeout <= 0 {
+		p.authTimeout = defaultAuthTimeout
+	}
+	if p.idleTimeout < 0 {
+		p.idleTimeout = 0
+	}
+	p.ping = pingInterval(cfg, p.timeout)
+	return p
+}
+
+// pingInterval 服务端 ping 间隔，不超过心跳超时时间的一半
+func pingInterval(cfg dto.HeartbeatConfig, timeout time.Duration) time.Duration {
+	if !cfg.ServerPing {
+		return 0
+	}
+	interval := time.Duration(cfg.PingInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultPingInterval
+	}
+	if interval > timeout/2 {
+		interval = timeout / 2
+	}
+	return interval
+}
+
+// watch 连接建立后启动超时定时器，先按认证超时检查
+func (w *Server) watch(c gnet.Conn, wsc *wsCodec) {
+	wsc.timer = w.wheel.NewTimer(func() {
+		w.checkConn(c, wsc)
+	})
+	w.wheel.Reset(wsc.timer, w.heartbeat.Load().authTimeout)
+}
+
+// unwatch 连接关闭后停止定时器
+func (w *Server) unwatch(wsc *wsCodec) {
+	wsc.closed.Store(true)
+	if wsc.timer != nil {
+		w.wheel.Stop(wsc.timer)
+	}
+}
+
+// checkConn 在时间轮中执行：未认证连接到期即关闭；已认证连接检查心跳与空闲超时、发送 ping，并按最近的截止时间重新计时
+func (w *Server) checkConn(c gnet.Conn, wsc *wsCodec) {
+	if wsc.closed.Load() {
+		return
+	}
+	p := w.heartbeat.Load()
+	if wsc.UID() == 0 {
+		logger.Log.Infof("conn[%v] auth timeout", c.RemoteAddr().String())
+		asyncClose(c, CloseAuthTimeout)
+		w.unauthConn.Delete(c)
+		return
+	}
+
+	now := time.Now().UnixNano()
+	next := wsc.lastSeen.Load() + int64(p.timeout)
+	if now >= next {
+		logger.Log.Infof("clear heartbeat timeout client, uid %d", wsc.UID())
+		asyncClose(c, CloseHeartbeatTimeout)
+		return
+	}
+	if p.idleTimeout > 0 {
+		idleAt := wsc.lastActive.Load() + int64(p.idleTimeout)
+		if now >= idleAt {
+			logger.Log.Infof("clear idle client, uid %d", wsc.UID())
+			asyncClose(c, CloseIdleTimeout)
+			return
+		}
+		next = min(next, idleAt)
+	}
+	if p.ping > 0 {
+		if now-wsc.lastPing >= int64(p.ping) {
+			_ = c.AsyncWrite(pingFrame, nil)
+			wsc.lastPing = now
+		}
+		next = min(next, wsc.lastPing+int64(p.ping))
+	}
+	w.wheel.Reset(wsc.timer, time.Duration(next-now))
+}
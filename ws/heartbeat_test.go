@@ -0,0 +1,171 @@
+package ws
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/conn"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/timewheel"
+	"github.com/gobwas/ws"
+	"github.com/panjf2000/gnet/v2"
+)
+
+func TestControlFrameRefreshesHeartbeat(t *testing.T) {
+	wsc := NewWsCodec()
+	wsc.client = conn.NewClient(10001, "s1", "", nil)
+	wsc.client.LastHeartbeat = 0
+
+	var frame bytes.Buffer
+	if err := ws.WriteFrame(&frame, ws.MaskFrame(ws.NewPingFrame([]byte("hi")))); err != nil {
+		t.Fatal(err)
+	}
+	wsc.buf.Write(frame.Bytes())
+	messages, err := wsc.readWsMessages()
+	if err != nil || len(messages) != 1 || messages[0].OpCode != ws.OpPing {
+		t.Fatalf("unexpected messages %v %v", messages, err)
+	}
+	if time.Now().Unix()-wsc.client.Heartbeat() > 1 {
+		t.Fatal("ping frame did not refresh heartbeat")
+	}
+}
+
+func TestHeartbeatPolicy(t *testing.T) {
+	cases := []struct {
+		cfg  dto.HeartbeatConfig
+		want heartbeatPolicy
+	}{
+		{dto.HeartbeatConfig{}, heartbeatPolicy{timeout: 30 * time.Second, authTimeout: 5 * time.Second}},
+		{dto.HeartbeatConfig{ServerPing: true}, heartbeatPolicy{timeout: 30 * time.Second, authTimeout: 5 * time.Second, ping: defaultPingInterval}},
+		{dto.HeartbeatConfig{Timeout: 60, AuthTimeout: 3, IdleTimeout: 300, ServerPing: true, PingInterval: 5},
+			heartbeatPolicy{timeout: time.Minute, authTimeout: 3 * time.Second, idleTimeout: 5 * time.Minute, ping: 5 * time.Second}},
+		{dto.HeartbeatConfig{ServerPing: true, PingInterval: 60}, heartbeatPolicy{timeout: 30 * time.Second, authTimeout: 5 * time.Second, ping: 15 * time.Second}},
+	}
+	for _, c := range cases {
+		if got := newHeartbeatPolicy(c.cfg); *got != c.want {
+			t.Errorf("newHeartbeatPolicy(%+v) = %+v, want %+v", c.cfg, *got, c.want)
+		}
+	}
+}
+
+// fakeConn 记录异步写入与关闭的 gnet.Conn
+type fakeConn struct {
+	gnet.Conn
+	ctx      any
+	mu       sync.Mutex
+	writes   [][]byte
+	closed   bool
+	hold     bool     // 暂不执行写入回调，模拟事件循环尚未处理
+	pending  []func() // hold 期间的写入回调
+	buffered int      // OutboundBuffered 返回值
+}
+
+func (f *fakeConn) Context() any         { return f.ctx }
+func (f *fakeConn) SetContext(ctx any)   { f.ctx = ctx }
+func (f *fakeConn) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)} }
+
+func (f *fakeConn) AsyncWrite(b []byte, cb gnet.AsyncCallback) error {
+	return f.AsyncWritev([][]byte{b}, cb)
+}
+
+func (f *fakeConn) AsyncWritev(bs [][]byte, cb gnet.AsyncCallback) error {
+	f.mu.Lock()
+	f.writes = append(f.writes, bs...)
+	if f.hold && cb != nil {
+		f.pending = append(f.pending, func() { _ = cb(f, nil) })
+		f.mu.Unlock()
+		return nil
+	}
+	f.mu.Unlock()
+	if cb != nil {
+		return cb(f, nil)
+	}
+	return nil
+}
+
+func (f *fakeConn) OutboundBuffered() int { return f.buffered }
+
+func (f *fakeConn) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes = append(f.writes, b)
+	return len(b), nil
+}
+
+// release 执行 hold 期间的写入回调
+func (f *fakeConn) release() {
+	f.mu.Lock()
+	pending := f.pending
+	f.pending, f.hold = nil, false
+	f.mu.Unlock()
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+func (f *fakeConn) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	return nil
+}
+
+func newWatchedConn(srv *Server, uid int64) (*fakeConn, *wsCodec) {
+	wsc := NewWsCodec()
+	wsc.upgraded.Store(true)
+	if uid != 0 {
+		_ = wsc.Bind(uid)
+	}
+	c := &fakeConn{ctx: wsc}
+	srv.watch(c, wsc)
+	return c, wsc
+}
+
+func TestCheckConn(t *testing.T) {
+	srv := &Server{wheel: timewheel.New(wheelTick)}
+	srv.heartbeat.Store(newHeartbeatPolicy(dto.HeartbeatConfig{IdleTimeout: 60, ServerPing: true}))
+
+	// 未认证连接到期即关闭
+	c, wsc := newWatchedConn(srv, 0)
+	srv.checkConn(c, wsc)
+	if !c.closed || CloseReason(wsc.closeReason.Load()) != CloseAuthTimeout {
+		t.Fatalf("unauthenticated conn: closed=%v reason=%v", c.closed, CloseReason(wsc.closeReason.Load()))
+	}
+
+	// 存活连接发送 ping 并重新计时
+	c, wsc = newWatchedConn(srv, 10001)
+	srv.checkConn(c, wsc)
+	if c.closed || len(c.writes) != 1 || !bytes.Equal(c.writes[0], pingFrame) {
+		t.Fatalf("alive conn: closed=%v writes=%d", c.closed, len(c.writes))
+	}
+	if !srv.wheel.Stop(wsc.timer) {
+		t.Fatal("timer not rescheduled")
+	}
+
+	// 心跳超时
+	c, wsc = newWatchedConn(srv, 10001)
+	wsc.lastSeen.Store(time.Now().Add(-31 * time.Second).UnixNano())
+	srv.checkConn(c, wsc)
+	if !c.closed || CloseReason(wsc.closeReason.Load()) != CloseHeartbeatTimeout {
+		t.Fatal("heartbeat timeout not closed")
+	}
+
+	// 只有心跳没有业务消息
+	c, wsc = newWatchedConn(srv, 10001)
+	wsc.lastActive.Store(time.Now().Add(-61 * time.Second).UnixNano())
+	srv.checkConn(c, wsc)
+	if !c.closed || CloseReason(wsc.closeReason.Load()) != CloseIdleTimeout {
+		t.Fatal("idle conn not closed")
+	}
+
+	// 已关闭的连接不再检查
+	c, wsc = newWatchedConn(srv, 0)
+	srv.unwatch(wsc)
+	srv.checkConn(c, wsc)
+	if c.closed {
+		t.Fatal("closed conn checked")
+	}
+}
@@ -0,0 +1,167 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/aluka-7/game-gateway/utils/timewheel"
+)
+
+const (
+	// LateDiscard 丢弃超时后到达的应答
+	LateDiscard = "discard"
+	// LateFlag 超时后到达的应答标记 late 后照常下发
+	LateFlag = "flag"
+
+	// lateWindow 请求超时后继续识别迟到应答的时间，之后的应答按普通推送下发
+	lateWindow = time.Minute
+)
+
+// requestPolicy 请求超时配置，配置变更后对之后的请求生效
+type requestPolicy struct {
+	timeout time.Duration
+	events  map[string]time.Duration // "server/event"
+	late    string
+}
+
+func newRequestPolicy(cfg dto.RequestConfig) *requestPolicy {
+	p := &requestPolicy{
+		timeout: time.Duration(cfg.Timeout) * time.Millisecond,
+		events:  make(map[string]time.Duration, len(cfg.Events)),
+		late:    cfg.Late,
+	}
+	for key, ms := range cfg.Events {
+		p.events[key] = time.Duration(ms) * time.Millisecond
+	}
+	switch p.late {
+	case LateDiscard, LateFlag:
+	default:
+		if p.late != "" {
+			logger.Log.Errorf("unknown late reply policy %q, use %q", p.late, LateDiscard)
+		}
+		p.late = LateDiscard
+	}
+	return p
+}
+
+// deadline 事件的应答超时，0 表示不限制
+func (p *requestPolicy) deadline(server, event string) time.Duration {
+	if d, ok := p.events[server+"/"+event]; ok {
+		return d
+	}
+	return p.timeout
+}
+
+type inflightKey struct {
+	uid int64
+	seq int64
+}
+
+type inflightReq struct {
+	server  string
+	event   string
+	timer   *timewheel.Timer
+	expired bool // 已回复超时，等待迟到的应答
+}
+
+// inflight 等待游戏服务应答的请求，按 (userId, seq) 索引
+type inflight struct {
+	mu   sync.Mutex
+	reqs map[inflightKey]*inflightReq
+}
+
+func newInflight() *inflight {
+	return &inflight{reqs: make(map[inflightKey]*inflightReq)}
+}
+
+// Len 等待应答与等待迟到应答的请求数
+func (f *inflight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.reqs)
+}
+
+// track 记录发给游戏服务的请求，seq 为 0 或事件不限制超时时不记录
+//
+// 同一用户重复使用 seq 时替换之前的请求。
+func (w *Server) track(req *dto.CommonReq) {
+	if req.Seq == 0 {
+		return
+	}
+	d := w.request.Load().deadline(req.Server, req.Event)
+	if d <= 0 {
+		return
+	}
+	key := inflightKey{req.UserId, req.Seq}
+	r := &inflightReq{server: req.Server, event: req.Event}
+	r.timer = w.wheel.NewTimer(func() {
+		w.expire(key, r)
+	})
+	f := w.inflight
+	f.mu.Lock()
+	if old, ok := f.reqs[key]; ok {
+		w.wheel.Stop(old.timer)
+	}
+	f.reqs[key] = r
+	f.mu.Unlock()
+	w.wheel.Reset(r.timer, d)
+}
+
+// expire 超时回复客户端，之后在 lateWindow 内继续识别迟到的应答
+func (w *Server) expire(key inflightKey, r *inflightReq) {
+	f := w.inflight
+	f.mu.Lock()
+	if f.reqs[key] != r {
+		f.mu.Unlock()
+		return
+	}
+	if r.expired {
+		delete(f.reqs, key)
+		f.mu.Unlock()
+		return
+	}
+	r.expired = true
+	w.wheel.Reset(r.timer, lateWindow)
+	f.mu.Unlock()
+
+	w.sendToUser(&dto.CommonRes{
+		Server: r.server,
+		Event:  r.event,
+		Seq:    key.seq,
+		UserId: key.uid,
+		Code:   dto.CodeTimeout,
+		Msg:    dto.CodeMsg(dto.CodeTimeout),
+	})
+}
+
+// resolve 匹配游戏服务的应答，返回 false 表示应答已超时且应当丢弃
+//
+// 没有对应请求的消息视为推送，照常下发。
+func (w *Server) resolve(msg *dto.CommonRes) bool {
+	if msg.Seq == 0 {
+		return true
+	}
+	key := inflightKey{msg.UserId, msg.Seq}
+	f := w.inflight
+	f.mu.Lock()
+	r, ok := f.reqs[key]
+	if !ok || r.server != msg.Server {
+		f.mu.Unlock()
+		return true
+	}
+	delete(f.reqs, key)
+	w.wheel.Stop(r.timer)
+	f.mu.Unlock()
+
+	if !r.expired {
+		return true
+	}
+	if w.request.Load().late == LateFlag {
+		msg.Late = true
+		return true
+	}
+	logger.Log.Debugf("discard late reply %s/%s seq %d to user %d", msg.Server, msg.Event, msg.Seq, msg.UserId)
+	return false
+}
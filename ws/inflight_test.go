@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+)
+
+func TestInflight(t *testing.T) {
+	srv := newRoomServer()
+	srv.request.Store(newRequestPolicy(dto.RequestConfig{Timeout: 200, Events: map[string]int{"wingo/free": 0}}))
+	a, _ := newRoomClient(srv, 10001)
+	now := time.Now()
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		srv.wheel.Advance(now)
+	}
+	last := func() dto.CommonRes {
+		t.Helper()
+		var res dto.CommonRes
+		if err := json.Unmarshal(a.writes[len(a.writes)-1][2:], &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	req := func(event string, seq int64) {
+		srv.track(&dto.CommonReq{Server: "wingo", Event: event, Seq: seq, UserId: 10001})
+	}
+	reply := func(seq int64) {
+		srv.dispatch(&dto.CommonRes{Server: "wingo", Event: "bet", Seq: seq, UserId: 10001})
+	}
+
+	// 按时应答
+	req("bet", 1)
+	reply(1)
+	if len(a.writes) != 1 || last().Code != dto.CodeOK || srv.inflight.Len() != 0 {
+		t.Fatalf("reply in time: writes=%d inflight=%d", len(a.writes), srv.inflight.Len())
+	}
+
+	// 超时后回复错误，迟到的应答被丢弃
+	req("bet", 2)
+	advance(300 * time.Millisecond)
+	if res := last(); len(a.writes) != 2 || res.Code != dto.CodeTimeout || res.Seq != 2 || res.Event != "bet" {
+		t.Fatalf("timeout: writes=%d last=%+v", len(a.writes), res)
+	}
+	reply(2)
+	if len(a.writes) != 2 || srv.inflight.Len() != 0 {
+		t.Fatalf("late reply delivered: writes=%d", len(a.writes))
+	}
+
+	// flag 策略下迟到的应答标记 late 后下发
+	srv.request.Store(newRequestPolicy(dto.RequestConfig{Timeout: 200, Late: LateFlag}))
+	req("bet", 3)
+	advance(300 * time.Millisecond)
+	reply(3)
+	if res := last(); len(a.writes) != 4 || !res.Late || res.Seq != 3 {
+		t.Fatalf("flagged late reply: writes=%d last=%+v", len(a.writes), res)
+	}
+
+	// 超过 lateWindow 后不再记录
+	req("bet", 4)
+	advance(300 * time.Millisecond)
+	advance(lateWindow + time.Second)
+	if srv.inflight.Len() != 0 {
+		t.Fatal("expired request not removed")
+	}
+
+	// 没有对应请求的消息照常下发
+	reply(99)
+	if res := last(); len(a.writes) != 6 || res.Seq != 99 || res.Late {
+		t.Fatalf("push: writes=%d last=%+v", len(a.writes), res)
+	}
+
+	// 不限制超时的事件与 seq 为 0 的请求不记录
+	srv.request.Store(newRequestPolicy(dto.RequestConfig{Timeout: 200, Events: map[string]int{"wingo/free": 0}}))
+	req("free", 5)
+	req("bet", 0)
+	if srv.inflight.Len() != 0 {
+		t.Fatal("untracked request recorded")
+	}
+}
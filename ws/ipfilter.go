@@ -0,0 +1,132 @@
+package ws
+
+import (
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/panjf2000/gnet/v2"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// ipFilter IP 黑白名单，配置变更时整体替换
+type ipFilter struct {
+	allow    []netip.Prefix
+	deny     []netip.Prefix
+	maxConns int
+}
+
+func newIpFilter(cfg dto.IpFilterConfig) *ipFilter {
+	return &ipFilter{
+		allow:    parsePrefixes(cfg.Allow),
+		deny:     parsePrefixes(cfg.Deny),
+		maxConns: cfg.MaxConnsPerIp,
+	}
+}
+
+// parsePrefixes 解析 CIDR 列表，单个 IP 视为掩码全长的网段，非法配置跳过
+func parsePrefixes(list []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				logger.Log.Errorf("invalid ip filter entry %q: %v", s, err)
+				continue
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			logger.Log.Errorf("invalid ip filter entry %q: %v", s, err)
+			continue
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed 黑名单优先；配置了白名单时只放行白名单内的 IP
+func (f *ipFilter) Allowed(ip string) bool {
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if containsAddr(f.deny, addr) {
+		return false
+	}
+	return len(f.allow) == 0 || containsAddr(f.allow, addr)
+}
+
+// ipConns 按 IP 统计的在线连接数
+type ipConns struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newIpConns() *ipConns {
+	return &ipConns{counts: make(map[string]int)}
+}
+
+// Acquire 连接数未达到 max 时计数加一，max 为 0 表示不限制
+func (n *ipConns) Acquire(ip string, max int) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if max > 0 && n.counts[ip] >= max {
+		return false
+	}
+	n.counts[ip]++
+	return true
+}
+
+func (n *ipConns) Release(ip string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.counts[ip] <= 1 {
+		delete(n.counts, ip)
+		return
+	}
+	n.counts[ip]--
+}
+
+// Count 返回 IP 的在线连接数
+func (n *ipConns) Count(ip string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.counts[ip]
+}
+
+// dropDenied 配置变更后关闭已被禁止 IP 的连接
+func (w *Server) dropDenied(f *ipFilter) {
+	closeDenied := func(c gnet.Conn) {
+		if ip := remoteIP(c); !f.Allowed(ip) {
+			logger.Log.Infof("close conn from denied ip[%s]", ip)
+			asyncClose(c, CloseIpDenied)
+		}
+	}
+	w.unauthConn.Range(func(key, _ any) bool {
+		closeDenied(key.(gnet.Conn))
+		return true
+	})
+	for _, item := range w.connMgr.Snapshot() {
+		closeDenied(item.Client.Conn)
+	}
+}
@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"github.com/aluka-7/game-gateway/dto"
+	"testing"
+)
+
+func TestIpFilter(t *testing.T) {
+	f := newIpFilter(dto.IpFilterConfig{
+		Allow: []string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"},
+		Deny:  []string{"10.1.0.0/16", "bad-entry"},
+	})
+	cases := map[string]bool{
+		"10.0.0.1":        true,
+		"10.1.2.3":        false, // 黑名单优先
+		"192.168.1.10":    true,
+		"192.168.1.11":    false,
+		"::ffff:10.0.0.1": true,
+		"2001:db8::1":     true,
+		"2001:db9::1":     false,
+		"not-an-ip":       false,
+	}
+	for ip, want := range cases {
+		if got := f.Allowed(ip); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestIpFilterDenyOnly(t *testing.T) {
+	f := newIpFilter(dto.IpFilterConfig{Deny: []string{"1.2.3.0/24"}})
+	if f.Allowed("1.2.3.4") || !f.Allowed("1.2.4.1") {
+		t.Fatal("deny list not applied")
+	}
+	if !newIpFilter(dto.IpFilterConfig{}).Allowed("") {
+		t.Fatal("empty filter should allow all")
+	}
+}
+
+func TestIpConns(t *testing.T) {
+	n := newIpConns()
+	if !n.Acquire("1.1.1.1", 2) || !n.Acquire("1.1.1.1", 2) {
+		t.Fatal("under cap should be allowed")
+	}
+	if n.Acquire("1.1.1.1", 2) {
+		t.Fatal("over cap should be rejected")
+	}
+	n.Release("1.1.1.1")
+	if !n.Acquire("1.1.1.1", 2) {
+		t.Fatal("released slot should be reusable")
+	}
+	n.Release("1.1.1.1")
+	n.Release("1.1.1.1")
+	if c := n.Count("1.1.1.1"); c != 0 || len(n.counts) != 0 {
+		t.Fatalf("count = %d, want 0", c)
+	}
+}
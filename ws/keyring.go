@@ -0,0 +1,236 @@
+package ws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"strings"
+)
+
+var (
+	ErrUnknownKid = errors.New("unknown jwt kid")
+	ErrKeyAlg     = errors.New("jwt alg mismatch")
+)
+
+// verifyKey 单个校验密钥
+type verifyKey struct {
+	alg string
+	key interface{} // []byte / *rsa.PublicKey / *ecdsa.PublicKey
+}
+
+// keyring 当前生效的校验密钥集合，构建后只读，整体替换实现热更新
+type keyring struct {
+	keys map[string]verifyKey // kid -> key
+	algs []string
+}
+
+func newKeyring(cfg dto.JwtConfig) (*keyring, error) {
+	kr := &keyring{keys: make(map[string]verifyKey)}
+	for _, k := range cfg.Keys {
+		vk, err := parseConfigKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key[%s]: %w", k.Kid, err)
+		}
+		if err = kr.add(k.Kid, vk); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.JwksFile != "" {
+		raw, err := os.ReadFile(cfg.JwksFile)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := parseJwks(raw)
+		if err != nil {
+			return nil, fmt.Errorf("jwks[%s]: %w", cfg.JwksFile, err)
+		}
+		for kid, vk := range keys {
+			if err = kr.add(kid, vk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return kr, nil
+}
+
+func (kr *keyring) add(kid string, vk verifyKey) error {
+	if _, ok := kr.keys[kid]; ok {
+		return fmt.Errorf("duplicate jwt kid: %q", kid)
+	}
+	kr.keys[kid] = vk
+	for _, alg := range kr.algs {
+		if alg == vk.alg {
+			return nil
+		}
+	}
+	kr.algs = append(kr.algs, vk.alg)
+	return nil
+}
+
+// keyfunc 按 token header 中的 kid 选择密钥；未携带 kid 时使用 kid 为空的密钥，只有一个密钥时直接使用
+func (kr *keyring) keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	vk, ok := kr.keys[kid]
+	if !ok && kid == "" && len(kr.keys) == 1 {
+		for _, v := range kr.keys {
+			vk, ok = v, true
+		}
+	}
+	if !ok {
+		return nil, ErrUnknownKid
+	}
+	if t.Method.Alg() != vk.alg {
+		return nil, ErrKeyAlg
+	}
+	return vk.key, nil
+}
+
+func parseConfigKey(k dto.JwtKey) (verifyKey, error) {
+	switch {
+	case k.Secret != "":
+		return checkKey(k.Alg, "HS256", []byte(k.Secret))
+	case k.PublicKey != "":
+		pem := []byte(k.PublicKey)
+		if pub, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+			return checkKey(k.Alg, "RS256", pub)
+		}
+		pub, err := jwt.ParseECPublicKeyFromPEM(pem)
+		if err != nil {
+			return verifyKey{}, errors.New("unsupported public key")
+		}
+		return checkKey(k.Alg, ecAlg(pub.Curve), pub)
+	}
+	return verifyKey{}, errors.New("missing secret or publicKey")
+}
+
+// checkKey 校验算法与密钥类型是否匹配，alg 为空时使用默认算法
+func checkKey(alg, def string, key interface{}) (verifyKey, error) {
+	if alg == "" {
+		alg = def
+	}
+	var ok bool
+	switch jwt.GetSigningMethod(alg).(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok = key.([]byte)
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok = key.(*rsa.PublicKey)
+	case *jwt.SigningMethodECDSA:
+		_, ok = key.(*ecdsa.PublicKey)
+	}
+	if !ok {
+		return verifyKey{}, fmt.Errorf("alg %q does not match key type", alg)
+	}
+	return verifyKey{alg: alg, key: key}, nil
+}
+
+func ecAlg(curve elliptic.Curve) string {
+	switch curve {
+	case elliptic.P384():
+		return "ES384"
+	case elliptic.P521():
+		return "ES512"
+	default:
+		return "ES256"
+	}
+}
+
+// jwk RFC 7517 JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func parseJwks(raw []byte) (map[string]verifyKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]verifyKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		vk, err := parseJwk(k)
+		if err != nil {
+			return nil, fmt.Errorf("kid[%s]: %w", k.Kid, err)
+		}
+		keys[k.Kid] = vk
+	}
+	return keys, nil
+}
+
+func parseJwk(k jwk) (verifyKey, error) {
+	switch k.Kty {
+	case "oct":
+		secret, err := b64(k.K)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		return checkKey(k.Alg, "HS256", secret)
+	case "RSA":
+		n, err := b64Int(k.N)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		e, err := b64Int(k.E)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		return checkKey(k.Alg, "RS256", &rsa.PublicKey{N: n, E: int(e.Int64())})
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return verifyKey{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64Int(k.X)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		y, err := b64Int(k.Y)
+		if err != nil {
+			return verifyKey{}, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return verifyKey{}, errors.New("point is not on curve")
+		}
+		return checkKey(k.Alg, ecAlg(curve), &ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+	}
+	return verifyKey{}, fmt.Errorf("unsupported kty %q", k.Kty)
+}
+
+func b64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func b64Int(s string) (*big.Int, error) {
+	b, err := b64(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,172 @@
+package ws
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func publicPEM(t *testing.T, pub interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ce := newMemCache()
+	ce.Set(context.Background(), dto.GetUserTokenKey(10001), "jti-1")
+	auth := NewAuthenticator(ce)
+	err = auth.Reload(dto.JwtConfig{Keys: []dto.JwtKey{
+		{Kid: "old", Secret: "old-secret"},
+		{Kid: "rsa", PublicKey: publicPEM(t, &rsaKey.PublicKey)},
+		{Kid: "ec", Alg: "ES256", PublicKey: publicPEM(t, &ecKey.PublicKey)},
+	}})
+	if err != nil {
+		t.Fatalf("reload keys: %v", err)
+	}
+
+	valid := []string{
+		signWith(t, jwt.SigningMethodHS256, "old", []byte("old-secret"), 10001, "jti-1"),
+		signWith(t, jwt.SigningMethodRS256, "rsa", rsaKey, 10001, "jti-1"),
+		signWith(t, jwt.SigningMethodES256, "ec", ecKey, 10001, "jti-1"),
+	}
+	for i, token := range valid {
+		if _, err = auth.Intercept(token); err != nil {
+			t.Fatalf("token %d: unexpected error: %v", i, err)
+		}
+	}
+
+	invalid := []string{
+		// 未知 kid
+		signWith(t, jwt.SigningMethodHS256, "new", []byte("old-secret"), 10001, "jti-1"),
+		// 多密钥时必须携带 kid
+		signWith(t, jwt.SigningMethodHS256, "", []byte("old-secret"), 10001, "jti-1"),
+		// 用 RSA 公钥当作 HMAC 密钥伪造
+		signWith(t, jwt.SigningMethodHS256, "rsa", []byte(publicPEM(t, &rsaKey.PublicKey)), 10001, "jti-1"),
+	}
+	for i, token := range invalid {
+		if _, err = auth.Intercept(token); !errors.Is(err, ErrTokenInvalid) {
+			t.Fatalf("token %d: got %v, want %v", i, err, ErrTokenInvalid)
+		}
+	}
+
+	// 轮换：下线旧密钥，加载失败的配置不影响当前密钥
+	if err = auth.Reload(dto.JwtConfig{Keys: []dto.JwtKey{{Kid: "bad"}}}); err == nil {
+		t.Fatal("expected error for key without material")
+	}
+	if _, err = auth.Intercept(valid[0]); err != nil {
+		t.Fatalf("keys changed after failed reload: %v", err)
+	}
+	if err = auth.Reload(dto.JwtConfig{Keys: []dto.JwtKey{{Kid: "rsa", PublicKey: publicPEM(t, &rsaKey.PublicKey)}}}); err != nil {
+		t.Fatalf("reload keys: %v", err)
+	}
+	if _, err = auth.Intercept(valid[0]); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("retired key: got %v, want %v", err, ErrTokenInvalid)
+	}
+	if _, err = auth.Intercept(valid[1]); err != nil {
+		t.Fatalf("rotated key: unexpected error: %v", err)
+	}
+}
+
+func TestKeyringJwksFile(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa-1",
+				"use": "sig",
+				"n":   b64url(rsaKey.N.Bytes()),
+				"e":   b64url(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec-1",
+				"crv": "P-256",
+				"x":   b64url(ecKey.X.FillBytes(make([]byte, 32))),
+				"y":   b64url(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+			{
+				"kty": "RSA",
+				"kid": "enc-1",
+				"use": "enc",
+			},
+		},
+	}
+	raw, _ := json.Marshal(set)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(file, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ce := newMemCache()
+	ce.Set(context.Background(), dto.GetUserTokenKey(10001), "jti-1")
+	auth := NewAuthenticator(ce)
+	if err = auth.Reload(dto.JwtConfig{JwksFile: file}); err != nil {
+		t.Fatalf("reload keys: %v", err)
+	}
+	if _, err = auth.Intercept(signWith(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, 10001, "jti-1")); err != nil {
+		t.Fatalf("rsa jwk: unexpected error: %v", err)
+	}
+	if _, err = auth.Intercept(signWith(t, jwt.SigningMethodES256, "ec-1", ecKey, 10001, "jti-1")); err != nil {
+		t.Fatalf("ec jwk: unexpected error: %v", err)
+	}
+
+	// 文件更新后 Refresh 生效
+	set["keys"] = []map[string]string{{"kty": "oct", "kid": "hs-1", "k": b64url([]byte("jwks-secret"))}}
+	raw, _ = json.Marshal(set)
+	if err = os.WriteFile(file, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err = os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err = auth.Refresh(); err != nil {
+		t.Fatalf("refresh keys: %v", err)
+	}
+	if _, err = auth.Intercept(signWith(t, jwt.SigningMethodHS256, "hs-1", []byte("jwks-secret"), 10001, "jti-1")); err != nil {
+		t.Fatalf("refreshed jwk: unexpected error: %v", err)
+	}
+	if _, err = auth.Intercept(signWith(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, 10001, "jti-1")); !errors.Is(err, ErrTokenInvalid) {
+		t.Fatalf("removed jwk: got %v, want %v", err, ErrTokenInvalid)
+	}
+}
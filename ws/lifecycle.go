@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aluka-7/game-gateway/conn"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+)
+
+// 发给游戏服务的用户生命周期事件，只发给 lifecycle.subscribers 中的游戏服务
+const (
+	// EventUserOnline 会话认证或重连成功，只在开启 lifecycle.presence 时发给全部订阅的游戏服务
+	EventUserOnline = "user_online"
+	// EventUserBind 会话开始向游戏服务发送消息，只发给该游戏服务
+	EventUserBind = "user_bind"
+	// EventUserUnbind 会话改为向其他游戏服务发送消息，只发给之前的游戏服务
+	EventUserUnbind = "user_unbind"
+	// EventUserOffline 会话断开，发给会话绑定过的游戏服务，开启 lifecycle.presence 时发给全部订阅的游戏服务
+	EventUserOffline = "user_offline"
+
+	// reasonRebind 会话绑定到其他游戏服务
+	reasonRebind = "rebind"
+	// reasonDisconnected 连接断开，没有更具体的原因
+	reasonDisconnected = "disconnected"
+)
+
+// lifecyclePolicy 生命周期事件订阅配置，配置变更后对之后的事件生效
+type lifecyclePolicy struct {
+	subscribers map[string]struct{}
+	presence    bool
+}
+
+func newLifecyclePolicy(cfg dto.LifecycleConfig) *lifecyclePolicy {
+	p := &lifecyclePolicy{subscribers: make(map[string]struct{}, len(cfg.Subscribers)), presence: cfg.Presence}
+	for _, alias := range cfg.Subscribers {
+		p.subscribers[alias] = struct{}{}
+	}
+	return p
+}
+
+// userOnline 会话认证或重连成功，此时会话尚未绑定游戏服务，只在开启 presence 时通知
+func (w *Server) userOnline(client *conn.Client) {
+	p := w.lifecycle.Load()
+	if !p.presence || len(p.subscribers) == 0 {
+		return
+	}
+	ev := w.userEvent(client, "", "")
+	for alias := range p.subscribers {
+		w.notifyUser(alias, EventUserOnline, client.UID, ev)
+	}
+}
+
+// userBind 会话绑定的游戏服务从 from 变为 to，from 为空表示首次绑定
+func (w *Server) userBind(wsc *wsCodec, from, to string) {
+	if !slices.Contains(wsc.bound, to) {
+		wsc.bound = append(wsc.bound, to)
+	}
+	p := w.lifecycle.Load()
+	if _, ok := p.subscribers[from]; ok {
+		w.notifyUser(from, EventUserUnbind, wsc.client.UID, w.userEvent(wsc.client, from, reasonRebind))
+	}
+	if _, ok := p.subscribers[to]; ok {
+		w.notifyUser(to, EventUserBind, wsc.client.UID, w.userEvent(wsc.client, to, ""))
+	}
+}
+
+// userOffline 会话断开，发给会话绑定过的订阅服务，调用前会话已从 connMgr 移除
+func (w *Server) userOffline(wsc *wsCodec, reason CloseReason) {
+	p := w.lifecycle.Load()
+	if len(p.subscribers) == 0 {
+		return
+	}
+	targets := wsc.bound
+	if p.presence {
+		targets = slices.Collect(maps.Keys(p.subscribers))
+	}
+	why := reason.String()
+	if reason == CloseNone {
+		why = reasonDisconnected
+	}
+	var ev *dto.UserEvent
+	for _, alias := range targets {
+		if _, ok := p.subscribers[alias]; !ok {
+			continue
+		}
+		if ev == nil {
+			ev = w.userEvent(wsc.client, wsc.String("server"), why)
+		}
+		w.notifyUser(alias, EventUserOffline, wsc.client.UID, ev)
+	}
+}
+
+func (w *Server) userEvent(client *conn.Client, server, reason string) *dto.UserEvent {
+	sessions, _ := w.connMgr.Get(client.UID)
+	return &dto.UserEvent{
+		SessionId: client.SessionId,
+		DeviceId:  client.DeviceId,
+		Server:    server,
+		Reason:    reason,
+		Sessions:  len(sessions),
+		Time:      time.Now().UnixMilli(),
+	}
+}
+
+// notifyUser 按 uid 发给游戏服务中负责该用户的实例，与用户的消息走同一队列，保持先后顺序
+func (w *Server) notifyUser(alias, event string, uid int64, ev *dto.UserEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+	// 在事件循环中调用，队列已满时丢弃，不阻塞其他连接
+	select {
+	case w.inMsg <- &dto.CommonReq{
+		Server: alias,
+		Event:  event,
+		UserId: uid,
+		Data:   data,
+		System: true,
+	}:
+	default:
+		w.lifecycleDropped.Add(1)
+	}
+}
@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aluka-7/game-gateway/dto"
+)
+
+func TestUserLifecycle(t *testing.T) {
+	srv := newRoomServer()
+	srv.inMsg = make(chan *dto.CommonReq, 16)
+	srv.lifecycle.Store(newLifecyclePolicy(dto.LifecycleConfig{Subscribers: []string{"wingo", "crash"}}))
+	_, client := newRoomClient(srv, 10001)
+
+	// 按目标服务收集事件
+	drain := func() map[string]*dto.UserEvent {
+		t.Helper()
+		got := make(map[string]*dto.UserEvent)
+		for len(srv.inMsg) > 0 {
+			msg := <-srv.inMsg
+			if !msg.System || msg.UserId != 10001 {
+				t.Fatalf("unexpected event %+v", msg)
+			}
+			var ev dto.UserEvent
+			if err := json.Unmarshal(msg.Data, &ev); err != nil || ev.SessionId != client.SessionId {
+				t.Fatalf("unexpected data %s", msg.Data)
+			}
+			got[msg.Server+"/"+msg.Event] = &ev
+		}
+		return got
+	}
+
+	wsc := client.Conn.Context().(*wsCodec)
+
+	// 未开启 presence 时认证不通知，此时会话尚未绑定游戏服务
+	srv.userOnline(client)
+	if got := drain(); len(got) != 0 {
+		t.Fatalf("online without presence: %v", got)
+	}
+
+	// 只通知绑定前后的游戏服务，未订阅的服务不通知
+	srv.userBind(wsc, "", "wingo")
+	got := drain()
+	if len(got) != 1 || got["wingo/"+EventUserBind].Server != "wingo" {
+		t.Fatalf("bind: %v", got)
+	}
+	srv.userBind(wsc, "wingo", "poker")
+	got = drain()
+	if ev := got["wingo/"+EventUserUnbind]; len(got) != 1 || ev.Reason != reasonRebind {
+		t.Fatalf("unbind: %v", got)
+	}
+
+	// 断开时只通知会话绑定过的订阅服务，从未绑定的 crash 不通知
+	srv.connMgr.Remove(client.UID, client)
+	wsc.Set("server", "poker")
+	srv.userOffline(wsc, CloseHeartbeatTimeout)
+	got = drain()
+	if ev := got["wingo/"+EventUserOffline]; len(got) != 1 || ev.Reason != "heartbeat_timeout" || ev.Server != "poker" || ev.Sessions != 0 {
+		t.Fatalf("offline: %v", got)
+	}
+	srv.userOffline(wsc, CloseNone)
+	if ev := drain()["wingo/"+EventUserOffline]; ev == nil || ev.Reason != reasonDisconnected {
+		t.Fatalf("offline without reason: %+v", ev)
+	}
+
+	// 未绑定游戏服务的会话断开时不通知
+	_, other := newRoomClient(srv, 10001)
+	srv.userOffline(other.Conn.Context().(*wsCodec), CloseClient)
+	if len(srv.inMsg) != 0 {
+		t.Fatalf("unbound session notified: %d", len(srv.inMsg))
+	}
+
+	// 开启 presence 后全部订阅的服务都收到上下线事件
+	srv.lifecycle.Store(newLifecyclePolicy(dto.LifecycleConfig{Subscribers: []string{"wingo", "crash"}, Presence: true}))
+	srv.userOnline(client)
+	if got = drain(); len(got) != 2 || got["crash/"+EventUserOnline] == nil {
+		t.Fatalf("presence online: %v", got)
+	}
+	srv.userOffline(wsc, CloseClient)
+	if got = drain(); len(got) != 2 || got["crash/"+EventUserOffline] == nil {
+		t.Fatalf("presence offline: %v", got)
+	}
+
+	// 未订阅时不发送
+	srv.lifecycle.Store(newLifecyclePolicy(dto.LifecycleConfig{}))
+	srv.userOnline(client)
+	srv.userBind(wsc, "", "wingo")
+	srv.userOffline(wsc, CloseClient)
+	if len(srv.inMsg) != 0 {
+		t.Fatalf("unsubscribed events sent: %d", len(srv.inMsg))
+	}
+
+	// 队列已满时丢弃并计数，不阻塞
+	srv.inMsg = make(chan *dto.CommonReq, 1)
+	srv.lifecycle.Store(newLifecyclePolicy(dto.LifecycleConfig{Subscribers: []string{"wingo", "poker"}}))
+	srv.userOffline(wsc, CloseClient)
+	if len(srv.inMsg) != 1 || srv.lifecycleDropped.Load() != 1 {
+		t.Fatalf("queued %d dropped %d", len(srv.inMsg), srv.lifecycleDropped.Load())
+	}
+}
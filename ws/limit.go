@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"github.com/aluka-7/game-gateway/dto"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+const (
+	// violationWindow 统计超限次数的时间窗口
+	violationWindow = 10 * time.Second
+	// limiterIdle 用户/IP 限流器闲置超过该时长后回收
+	limiterIdle = time.Minute
+)
+
+func newLimiter(l dto.Limit) *rate.Limiter {
+	if l.Rate <= 0 {
+		return nil
+	}
+	burst := l.Burst
+	if burst < 1 {
+		burst = int(l.Rate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return rate.NewLimiter(rate.Limit(l.Rate), burst)
+}
+
+type limiterItem struct {
+	limiter  *rate.Limiter
+	lastSeen int64
+}
+
+// limiterSet 按 key 划分的令牌桶集合
+type limiterSet[K comparable] struct {
+	mu    sync.Mutex
+	limit dto.Limit
+	items map[K]*limiterItem
+}
+
+func newLimiterSet[K comparable](l dto.Limit) *limiterSet[K] {
+	return &limiterSet[K]{limit: l, items: make(map[K]*limiterItem)}
+}
+
+func (s *limiterSet[K]) Allow(key K) bool {
+	if s.limit.Rate <= 0 {
+		return true
+	}
+	now := time.Now()
+	s.mu.Lock()
+	item, ok := s.items[key]
+	if !ok {
+		item = &limiterItem{limiter: newLimiter(s.limit)}
+		s.items[key] = item
+	}
+	item.lastSeen = now.UnixNano()
+	s.mu.Unlock()
+	return item.limiter.AllowN(now, 1)
+}
+
+// Sweep 回收闲置的限流器
+func (s *limiterSet[K]) Sweep(idle time.Duration) {
+	deadline := time.Now().Add(-idle).UnixNano()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, item := range s.items {
+		if item.lastSeen < deadline {
+			delete(s.items, k)
+		}
+	}
+}
+
+// limits 当前生效的限流配置，配置变更时整体替换
+type limits struct {
+	cfg  dto.RateLimitConfig
+	user *limiterSet[int64]
+	ip   *limiterSet[string]
+}
+
+func newLimits(cfg dto.RateLimitConfig) *limits {
+	return &limits{
+		cfg:  cfg,
+		user: newLimiterSet[int64](cfg.User),
+		ip:   newLimiterSet[string](cfg.Ip),
+	}
+}
+
+// connLimiter 单连接的上行消息限流
+type connLimiter struct {
+	gen         *limits // 创建时的限流配置，配置变更后重建
+	limiter     *rate.Limiter
+	events      map[string]*rate.Limiter
+	violations  int
+	violationAt time.Time
+}
+
+func newConnLimiter(l *limits) *connLimiter {
+	cl := &connLimiter{gen: l, limiter: newLimiter(l.cfg.Conn)}
+	if len(l.cfg.Events) > 0 {
+		cl.events = make(map[string]*rate.Limiter, len(l.cfg.Events))
+		for key, limit := range l.cfg.Events {
+			cl.events[key] = newLimiter(limit)
+		}
+	}
+	return cl
+}
+
+func (cl *connLimiter) allow(server, event string) bool {
+	if limiter, ok := cl.events[server+"/"+event]; ok {
+		return limiter == nil || limiter.Allow()
+	}
+	return cl.limiter == nil || cl.limiter.Allow()
+}
+
+// violate 记录一次超限，返回窗口内的超限次数
+func (cl *connLimiter) violate(now time.Time) int {
+	if now.Sub(cl.violationAt) > violationWindow {
+		cl.violations = 0
+		cl.violationAt = now
+	}
+	cl.violations++
+	return cl.violations
+}
+
+// allowMessage 依次检查单连接（或事件覆盖）与单用户限流，在事件循环中调用
+func (w *Server) allowMessage(wsc *wsCodec, msg *dto.CommonReq) bool {
+	l := w.limits.Load()
+	if wsc.limiter == nil || wsc.limiter.gen != l {
+		wsc.limiter = newConnLimiter(l)
+	}
+	if !wsc.limiter.allow(msg.Server, msg.Event) {
+		return false
+	}
+	if uid := wsc.UID(); uid != 0 {
+		return l.user.Allow(uid)
+	}
+	return true
+}
+
+// rateViolated 记录超限，达到上限时返回 true 表示应关闭连接
+func (w *Server) rateViolated(wsc *wsCodec) bool {
+	max := wsc.limiter.gen.cfg.MaxViolations
+	return wsc.limiter.violate(time.Now()) >= max && max > 0
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"github.com/aluka-7/game-gateway/dto"
+	"testing"
+	"time"
+)
+
+func TestLimiterSet(t *testing.T) {
+	s := newLimiterSet[int64](dto.Limit{Rate: 1, Burst: 2})
+	if !s.Allow(1) || !s.Allow(1) {
+		t.Fatal("burst should be allowed")
+	}
+	if s.Allow(1) {
+		t.Fatal("exceeded burst should be limited")
+	}
+	if !s.Allow(2) {
+		t.Fatal("other key should not be affected")
+	}
+	s.Sweep(0)
+	if len(s.items) != 0 {
+		t.Fatalf("idle limiters not swept: %d", len(s.items))
+	}
+}
+
+func TestLimiterSetUnlimited(t *testing.T) {
+	s := newLimiterSet[string](dto.Limit{})
+	for i := 0; i < 100; i++ {
+		if !s.Allow("127.0.0.1") {
+			t.Fatal("zero rate should be unlimited")
+		}
+	}
+}
+
+func TestConnLimiterEvents(t *testing.T) {
+	l := newLimits(dto.RateLimitConfig{
+		Conn:   dto.Limit{Rate: 1, Burst: 1},
+		Events: map[string]dto.Limit{"system/ping": {}},
+	})
+	cl := newConnLimiter(l)
+	if !cl.allow("game", "move") || cl.allow("game", "move") {
+		t.Fatal("conn limit not applied")
+	}
+	for i := 0; i < 10; i++ {
+		if !cl.allow("system", "ping") {
+			t.Fatal("event override should be unlimited")
+		}
+	}
+}
+
+func TestAllowMessage(t *testing.T) {
+	w := &Server{}
+	w.limits.Store(newLimits(dto.RateLimitConfig{
+		User:          dto.Limit{Rate: 1, Burst: 1},
+		MaxViolations: 2,
+	}))
+	a, b := NewWsCodec(), NewWsCodec()
+	a.Bind(1)
+	b.Bind(1)
+	msg := &dto.CommonReq{Server: "game", Event: "move"}
+	if !w.allowMessage(a, msg) {
+		t.Fatal("first message should be allowed")
+	}
+	// 同一用户的多个会话共享限额
+	if w.allowMessage(b, msg) {
+		t.Fatal("user limit should be shared between sessions")
+	}
+	if w.rateViolated(b) || !w.rateViolated(b) {
+		t.Fatal("connection should be closed after max violations")
+	}
+
+	// 配置变更后重建单连接限流器
+	w.limits.Store(newLimits(dto.RateLimitConfig{}))
+	if !w.allowMessage(b, msg) || b.limiter.violations != 0 {
+		t.Fatal("limits not reloaded")
+	}
+	if b.limiter.violate(time.Now().Add(violationWindow+time.Second)) != 1 {
+		t.Fatal("violations should reset after window")
+	}
+}
@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"net/url"
+	"strings"
+)
+
+// originPattern 允许的 Origin，scheme 与端口为空时不限制
+type originPattern struct {
+	scheme string
+	host   string // 小写主机名，以 "*." 开头时匹配任意子域名，"*" 匹配全部
+	port   string
+}
+
+func parseOriginPattern(s string) originPattern {
+	s = strings.ToLower(strings.TrimSpace(s))
+	var p originPattern
+	if i := strings.Index(s, "://"); i >= 0 {
+		p.scheme, s = s[:i], s[i+3:]
+	}
+	s = strings.TrimSuffix(s, "/")
+	// 端口只能出现在最后一个冒号之后，兼容 [::1]:8080 形式
+	if i := strings.LastIndex(s, ":"); i >= 0 && !strings.HasSuffix(s, "]") {
+		p.host, p.port = s[:i], s[i+1:]
+	} else {
+		p.host = s
+	}
+	p.host = strings.Trim(p.host, "[]")
+	return p
+}
+
+func (p originPattern) match(scheme, host, port string) bool {
+	if p.scheme != "" && p.scheme != scheme {
+		return false
+	}
+	if p.port != "" && p.port != port {
+		return false
+	}
+	switch {
+	case p.host == "*":
+		return true
+	case strings.HasPrefix(p.host, "*."):
+		return strings.HasSuffix(host, p.host[1:])
+	default:
+		return p.host == host
+	}
+}
+
+// originMatcher Origin 白名单
+type originMatcher []originPattern
+
+func newOriginMatcher(origins []string) originMatcher {
+	m := make(originMatcher, 0, len(origins))
+	for _, o := range origins {
+		if strings.TrimSpace(o) != "" {
+			m = append(m, parseOriginPattern(o))
+		}
+	}
+	return m
+}
+
+// Allowed 未配置白名单或请求未携带 Origin（非浏览器客户端）时放行
+func (m originMatcher) Allowed(origin string) bool {
+	if len(m) == 0 || origin == "" {
+		return true
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http", "ws":
+			port = "80"
+		case "https", "wss":
+			port = "443"
+		}
+	}
+	for _, p := range m {
+		if p.match(u.Scheme, u.Hostname(), port) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package ws
+
+import (
+	"github.com/aluka-7/game-gateway/conn"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/panjf2000/gnet/v2"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultQueueSize   = 256
+	defaultMaxBuffered = 4 << 20
+	// stallRetry 写缓冲积压时重新检查的间隔
+	stallRetry = 100 * time.Millisecond
+)
+
+// outboundPolicy 下行发送队列配置，配置变更时整体替换，对之后入队的消息生效
+type outboundPolicy struct {
+	size        int
+	overflow    conn.Overflow
+	maxBuffered int
+}
+
+func newOutboundPolicy(cfg dto.OutboundConfig) *outboundPolicy {
+	p := &outboundPolicy{
+		size:        cfg.QueueSize,
+		overflow:    conn.Overflow(cfg.Overflow),
+		maxBuffered: cfg.MaxBuffered,
+	}
+	if p.size <= 0 {
+		p.size = defaultQueueSize
+	}
+	switch p.overflow {
+	case conn.OverflowDropOldest, conn.OverflowDropNewest, conn.OverflowDisconnect:
+	default:
+		if p.overflow != "" {
+			logger.Log.Errorf("unknown outbound overflow policy %q, use %q", p.overflow, conn.OverflowDropOldest)
+		}
+		p.overflow = conn.OverflowDropOldest
+	}
+	if p.maxBuffered <= 0 {
+		p.maxBuffered = defaultMaxBuffered
+	}
+	return p
+}
+
+// OutboundStats 发送队列已满时各策略处理的消息数
+type OutboundStats struct {
+	DroppedOldest uint64 `json:"droppedOldest"` // drop_oldest 丢弃的消息数
+	DroppedNewest uint64 `json:"droppedNewest"` // drop_newest 丢弃的消息数
+	Disconnected  uint64 `json:"disconnected"`  // disconnect 断开的连接数
+}
+
+type outboundCounters struct {
+	droppedOldest atomic.Uint64
+	droppedNewest atomic.Uint64
+	disconnected  atomic.Uint64
+}
+
+// OutboundStats 返回启动以来的丢弃统计
+func (w *Server) OutboundStats() OutboundStats {
+	return OutboundStats{
+		DroppedOldest: w.outStats.droppedOldest.Load(),
+		DroppedNewest: w.outStats.droppedNewest.Load(),
+		Disconnected:  w.outStats.disconnected.Load(),
+	}
+}
+
+// enqueue 编码下行消息并加入会话的发送队列，不会阻塞调用方
+func (w *Server) enqueue(client *conn.Client, f *outFrame) {
+	wsc, ok := client.Conn.Context().(*wsCodec)
+	if !ok {
+		return
+	}
+	frame, err := f.For(wsc)
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+	p := w.outbound.Load()
+	dropped, overflow := client.Queue.Push(frame, p.size, p.overflow)
+	if overflow {
+		w.outStats.disconnected.Add(1)
+		logger.Log.Infof("clear slow consumer, uid %d session %s", client.UID, client.SessionId)
+		asyncClose(client.Conn, CloseSlowConsumer)
+		return
+	}
+	if dropped > 0 {
+		if p.overflow == conn.OverflowDropNewest {
+			w.outStats.droppedNewest.Add(uint64(dropped))
+			return
+		}
+		w.outStats.droppedOldest.Add(uint64(dropped))
+	}
+	w.flush(client)
+}
+
+// flush 发送队列中的全部帧，同一会话同时只有一批在发送中
+func (w *Server) flush(client *conn.Client) {
+	if frames := client.Queue.Take(); frames != nil {
+		w.write(client, frames)
+	}
+}
+
+// write 在事件循环中写入 frames，frames 为空时只检查写缓冲
+func (w *Server) write(client *conn.Client, frames [][]byte) {
+	err := client.Conn.AsyncWritev(frames, func(c gnet.Conn, err error) error {
+		w.written(client, c, err)
+		return nil
+	})
+	if err != nil {
+		client.Queue.Done()
+	}
+}
+
+// written 在事件循环中执行：写缓冲积压时暂停发送，等待客户端读取后继续发送排队的消息
+func (w *Server) written(client *conn.Client, c gnet.Conn, err error) {
+	if err != nil {
+		// 连接已关闭
+		client.Queue.Done()
+		return
+	}
+	if c.OutboundBuffered() > w.outbound.Load().maxBuffered {
+		w.wheel.AfterFunc(stallRetry, func() {
+			w.write(client, nil)
+		})
+		return
+	}
+	if client.Queue.Done() {
+		w.flush(client)
+	}
+}
@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/conn"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/timewheel"
+)
+
+func newQueuedClient(srv *Server, uid int64) (*fakeConn, *conn.Client) {
+	c, wsc := newWatchedConn(srv, uid)
+	client := conn.NewClient(uid, "s1", "", c)
+	wsc.client = client
+	return c, client
+}
+
+func TestOutboundPolicy(t *testing.T) {
+	p := newOutboundPolicy(dto.OutboundConfig{Overflow: "unknown"})
+	if p.size != defaultQueueSize || p.overflow != conn.OverflowDropOldest || p.maxBuffered != defaultMaxBuffered {
+		t.Fatalf("unexpected default policy %+v", *p)
+	}
+}
+
+func TestEnqueueOverflow(t *testing.T) {
+	srv := &Server{wheel: timewheel.New(wheelTick)}
+	srv.heartbeat.Store(newHeartbeatPolicy(dto.HeartbeatConfig{}))
+
+	// 第一条发送中，之后两条排队，队列已满时丢弃新消息
+	srv.outbound.Store(newOutboundPolicy(dto.OutboundConfig{QueueSize: 2, Overflow: "drop_newest"}))
+	c, client := newQueuedClient(srv, 10001)
+	c.hold = true
+	for _, p := range []string{"1", "2", "3", "4"} {
+		srv.enqueue(client, newOutFrame([]byte(p)))
+	}
+	if stats := srv.OutboundStats(); stats.DroppedNewest != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	c.release()
+	if len(c.writes) != 3 || !bytes.HasSuffix(c.writes[2], []byte("3")) || client.Queue.Len() != 0 {
+		t.Fatalf("writes=%q queued=%d", c.writes, client.Queue.Len())
+	}
+
+	// disconnect 策略发送关闭帧后断开
+	srv.outbound.Store(newOutboundPolicy(dto.OutboundConfig{QueueSize: 1, Overflow: "disconnect"}))
+	c, client = newQueuedClient(srv, 10002)
+	c.hold = true
+	for _, p := range []string{"1", "2", "3"} {
+		srv.enqueue(client, newOutFrame([]byte(p)))
+	}
+	c.release()
+	wsc := c.ctx.(*wsCodec)
+	if !c.closed || CloseReason(wsc.closeReason.Load()) != CloseSlowConsumer || srv.OutboundStats().Disconnected != 1 {
+		t.Fatalf("slow consumer: closed=%v reason=%v", c.closed, CloseReason(wsc.closeReason.Load()))
+	}
+}
+
+func TestEnqueueStall(t *testing.T) {
+	srv := &Server{wheel: timewheel.New(wheelTick)}
+	srv.heartbeat.Store(newHeartbeatPolicy(dto.HeartbeatConfig{}))
+	srv.outbound.Store(newOutboundPolicy(dto.OutboundConfig{MaxBuffered: 10}))
+	c, client := newQueuedClient(srv, 10001)
+
+	// 写缓冲积压时消息留在队列中
+	c.buffered = 100
+	srv.enqueue(client, newOutFrame([]byte("1")))
+	srv.enqueue(client, newOutFrame([]byte("2")))
+	if len(c.writes) != 1 || client.Queue.Len() != 1 {
+		t.Fatalf("stalled: writes=%d queued=%d", len(c.writes), client.Queue.Len())
+	}
+
+	// 客户端读取后继续发送
+	c.buffered = 0
+	srv.wheel.Advance(time.Now().Add(2 * stallRetry))
+	if len(c.writes) != 2 || client.Queue.Len() != 0 {
+		t.Fatalf("resumed: writes=%d queued=%d", len(c.writes), client.Queue.Len())
+	}
+}
+
+func TestReplyQueued(t *testing.T) {
+	srv := &Server{wheel: timewheel.New(wheelTick)}
+	srv.heartbeat.Store(newHeartbeatPolicy(dto.HeartbeatConfig{}))
+	srv.outbound.Store(newOutboundPolicy(dto.OutboundConfig{MaxBuffered: 10}))
+	c, client := newQueuedClient(srv, 10001)
+
+	// 下行消息积压时回复排在其后
+	c.buffered = 100
+	srv.enqueue(client, newOutFrame([]byte("1")))
+	srv.enqueue(client, newOutFrame([]byte("2")))
+	srv.replyError(c, &dto.CommonReq{Server: "wingo", Event: "bet", Seq: 3}, dto.CodeRateLimited, "")
+	if len(c.writes) != 1 || client.Queue.Len() != 2 {
+		t.Fatalf("reply overtook queued frames: writes=%d queued=%d", len(c.writes), client.Queue.Len())
+	}
+	c.buffered = 0
+	srv.wheel.Advance(time.Now().Add(2 * stallRetry))
+	if len(c.writes) != 3 || !bytes.Contains(c.writes[2], []byte(`"seq":3`)) {
+		t.Fatalf("writes=%q", c.writes)
+	}
+
+	// 未认证的连接直接写入
+	c, wsc := newWatchedConn(srv, 0)
+	srv.replyError(c, &dto.CommonReq{}, dto.CodeBadRequest, "")
+	if wsc.client != nil || len(c.writes) != 1 {
+		t.Fatalf("unauthenticated reply: writes=%d", len(c.writes))
+	}
+}
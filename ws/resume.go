@@ -0,0 +1,65 @@
+package ws
+
+import (
+	"encoding/json"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/panjf2000/gnet/v2"
+)
+
+// handleResume 使用重连凭证恢复会话，并补发断线期间错过的消息
+//
+// 凭证只能使用一次，成功后下发新的凭证；缺口已无法补齐时仍完成绑定，返回 CodeResumeGap 提示客户端全量同步。
+func (w *Server) handleResume(c gnet.Conn, wsc *wsCodec, msg *dto.CommonReq) gnet.Action {
+	if wsc.client != nil {
+		w.replyError(c, msg, dto.CodeBadRequest, "already authenticated")
+		return gnet.None
+	}
+	var req dto.ResumeReq
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		logger.Log.Errorf("json.Unmarshal error: %+v", err)
+		w.replyError(c, msg, dto.CodeBadRequest, err.Error())
+		return closeConn(c, CloseBadRequest)
+	}
+	rt, o, ok := w.replay.Take(req.ResumeToken)
+	if ok && !w.auth.Active(rt.UID, rt.TokenId) {
+		w.releaseOutbox(rt.UID)
+		ok = false
+	}
+	if !ok {
+		logger.Log.Infof("conn[%v] resume failed: invalid resume token", c.RemoteAddr().String())
+		w.replyError(c, msg, dto.CodeUnauthenticated, "invalid resume token")
+		return closeConn(c, CloseUnauthenticated)
+	}
+
+	user := &UserClaims{
+		User:             User{Id: rt.UID, DeviceId: rt.DeviceId},
+		RegisteredClaims: jwt.RegisteredClaims{ID: rt.TokenId},
+	}
+	action := gnet.None
+	o.Replay(req.LastSeq, func(latest int64, complete bool) bool {
+		if err := w.authorize(c, wsc, user, o); err != nil {
+			w.releaseOutbox(rt.UID)
+			w.replyError(c, msg, dto.CodeLoginConflict, "")
+			action = closeConn(c, CloseLoginConflict)
+			return false
+		}
+		code := dto.CodeOK
+		if !complete {
+			code = dto.CodeResumeGap
+		}
+		w.replyAuth(c, wsc, EventResume, msg.Seq, code, latest)
+		return true
+	}, func(payload []byte) {
+		w.send(c, payload)
+	})
+	return action
+}
+
+// releaseOutbox 重连失败后，用户没有在线会话时恢复 Outbox 的过期时间
+func (w *Server) releaseOutbox(uid int64) {
+	if _, ok := w.connMgr.Get(uid); !ok {
+		w.replay.Release(uid)
+	}
+}
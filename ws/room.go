@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"encoding/json"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/logger"
+	"github.com/panjf2000/gnet/v2"
+)
+
+const defaultMaxRooms = 16
+
+// roomPolicy 公开房间配置，配置变更后对之后的加入请求生效
+type roomPolicy struct {
+	public   map[string]struct{} // roomKey
+	maxRooms int
+}
+
+func newRoomPolicy(cfg dto.RoomsConfig) *roomPolicy {
+	p := &roomPolicy{public: make(map[string]struct{}), maxRooms: cfg.MaxRooms}
+	for server, rooms := range cfg.Public {
+		for _, room := range rooms {
+			p.public[roomKey(server, room)] = struct{}{}
+		}
+	}
+	if p.maxRooms <= 0 {
+		p.maxRooms = defaultMaxRooms
+	}
+	return p
+}
+
+// roomKey 房间按游戏服务隔离，不同服务的同名房间互不影响
+func roomKey(server, room string) string {
+	return server + "/" + room
+}
+
+// handleRoomControl 处理游戏服务发来的房间控制消息
+//
+// room_join 将用户当前的全部会话加入房间，room_leave 移出用户，userId 为 0 时解散房间。
+func (w *Server) handleRoomControl(msg *dto.CommonRes) {
+	if msg.Room == "" {
+		logger.Log.Warnf("game server %s sent %s without room", msg.Server, msg.Event)
+		return
+	}
+	key := roomKey(msg.Server, msg.Room)
+	switch msg.Event {
+	case EventRoomJoin:
+		sessions, _ := w.connMgr.Get(msg.UserId)
+		for _, client := range sessions {
+			w.rooms.Join(client, key)
+		}
+	case EventRoomLeave:
+		if msg.UserId == 0 {
+			w.rooms.Clear(key)
+			return
+		}
+		sessions, _ := w.connMgr.Get(msg.UserId)
+		for _, client := range sessions {
+			w.rooms.Leave(client, key)
+		}
+	}
+}
+
+// publish 发给房间全部成员，帧只编码一次，房间消息不记录网关序号
+func (w *Server) publish(msg *dto.CommonRes) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+	frame := newOutFrame(payload)
+	for _, client := range w.rooms.Snapshot(roomKey(msg.Server, msg.Room)) {
+		w.enqueue(client, frame)
+	}
+}
+
+// handleRoom 客户端加入或离开公开房间
+func (w *Server) handleRoom(c gnet.Conn, wsc *wsCodec, msg *dto.CommonReq) {
+	var req dto.RoomReq
+	if err := json.Unmarshal(msg.Data, &req); err != nil || req.Server == "" || req.Room == "" {
+		w.replyError(c, msg, dto.CodeBadRequest, "server and room required")
+		return
+	}
+	p := w.roomPolicy.Load()
+	key := roomKey(req.Server, req.Room)
+	if _, ok := p.public[key]; !ok {
+		w.replyError(c, msg, dto.CodeRoomDenied, "")
+		return
+	}
+	if msg.Event == EventRoomJoin {
+		if w.rooms.Joined(wsc.client) >= p.maxRooms {
+			w.replyError(c, msg, dto.CodeRoomDenied, "too many rooms")
+			return
+		}
+		w.rooms.Join(wsc.client, key)
+	} else {
+		w.rooms.Leave(wsc.client, key)
+	}
+	w.reply(c, &dto.CommonRes{
+		Server: ServerSystem,
+		Event:  msg.Event,
+		Seq:    msg.Seq,
+		Code:   dto.CodeOK,
+		Msg:    dto.CodeMsg(dto.CodeOK),
+		Room:   req.Room,
+	})
+}
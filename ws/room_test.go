@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/conn"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/aluka-7/game-gateway/utils/timewheel"
+)
+
+func newRoomServer() *Server {
+	srv := &Server{
+		wheel:    timewheel.New(wheelTick),
+		connMgr:  conn.NewManager(),
+		members:  conn.NewMembers(),
+		rooms:    conn.NewRooms(),
+		replay:   conn.NewReplay(8, time.Minute),
+		inflight: newInflight(),
+	}
+	srv.login.Store(newLoginPolicy(dto.LoginConfig{}))
+	srv.request.Store(newRequestPolicy(dto.RequestConfig{}))
+	srv.lifecycle.Store(newLifecyclePolicy(dto.LifecycleConfig{}))
+	srv.heartbeat.Store(newHeartbeatPolicy(dto.HeartbeatConfig{}))
+	srv.outbound.Store(newOutboundPolicy(dto.OutboundConfig{}))
+	srv.roomPolicy.Store(newRoomPolicy(dto.RoomsConfig{Public: map[string][]string{"wingo": {"lobby"}}, MaxRooms: 1}))
+	return srv
+}
+
+func newRoomClient(srv *Server, uid int64) (*fakeConn, *conn.Client) {
+	c, client := newQueuedClient(srv, uid)
+	_, _ = srv.connMgr.Add(client, conn.PolicyMulti, 8)
+	return c, client
+}
+
+func TestRoomPublish(t *testing.T) {
+	srv := newRoomServer()
+	a, _ := newRoomClient(srv, 10001)
+	b, _ := newRoomClient(srv, 10002)
+
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: EventRoomJoin, UserId: 10001, Room: "t1", System: true})
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: EventRoomJoin, UserId: 10002, Room: "t1", System: true})
+	// 其他服务的同名房间互不影响
+	srv.dispatch(&dto.CommonRes{Server: "crash", Event: "bet", Room: "t1"})
+	if len(a.writes) != 0 {
+		t.Fatal("message leaked across games")
+	}
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: "deal", Room: "t1"})
+	if len(a.writes) != 1 || len(b.writes) != 1 || !bytes.Equal(a.writes[0], b.writes[0]) {
+		t.Fatalf("publish: a=%d b=%d", len(a.writes), len(b.writes))
+	}
+
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: EventRoomLeave, UserId: 10001, Room: "t1", System: true})
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: "deal", Room: "t1"})
+	if len(a.writes) != 1 || len(b.writes) != 2 {
+		t.Fatalf("after leave: a=%d b=%d", len(a.writes), len(b.writes))
+	}
+
+	// userId 为 0 时解散房间
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: EventRoomLeave, Room: "t1", System: true})
+	if srv.rooms.Count(roomKey("wingo", "t1")) != 0 {
+		t.Fatal("room not cleared")
+	}
+}
+
+func TestHandleRoom(t *testing.T) {
+	srv := newRoomServer()
+	c, client := newRoomClient(srv, 10001)
+	wsc := c.ctx.(*wsCodec)
+	join := func(server, room string) int {
+		data, _ := json.Marshal(dto.RoomReq{Server: server, Room: room})
+		c.writes = nil
+		srv.handleRoom(c, wsc, &dto.CommonReq{Server: ServerSystem, Event: EventRoomJoin, Seq: 1, Data: data})
+		var res dto.CommonRes
+		if len(c.writes) != 1 {
+			t.Fatalf("got %d replies", len(c.writes))
+		}
+		_ = json.Unmarshal(c.writes[0][2:], &res)
+		return res.Code
+	}
+	if code := join("wingo", "lobby"); code != dto.CodeOK || srv.rooms.Count(roomKey("wingo", "lobby")) != 1 {
+		t.Fatalf("join public room: code %d", code)
+	}
+	if code := join("wingo", "t1"); code != dto.CodeRoomDenied {
+		t.Fatalf("join private room: code %d", code)
+	}
+
+	// 超过上限
+	srv.roomPolicy.Store(newRoomPolicy(dto.RoomsConfig{Public: map[string][]string{"wingo": {"lobby", "chat"}}, MaxRooms: 1}))
+	if code := join("wingo", "chat"); code != dto.CodeRoomDenied {
+		t.Fatalf("join over limit: code %d", code)
+	}
+
+	// 关闭后清理
+	srv.rooms.Drop(client)
+	if srv.rooms.Count(roomKey("wingo", "lobby")) != 0 {
+		t.Fatal("membership not cleaned up")
+	}
+}
@@ -8,10 +8,11 @@ import (
 	"github.com/aluka-7/game-gateway/dto"
 	"github.com/aluka-7/game-gateway/tcp"
 	"github.com/aluka-7/game-gateway/utils/logger"
-	"github.com/gobwas/ws/wsutil"
+	"github.com/aluka-7/game-gateway/utils/timewheel"
 	"github.com/panjf2000/gnet/v2"
-	"golang.org/x/time/rate"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,9 +21,28 @@ const (
 )
 
 const (
-	EventAuth = "auth"
-	EventPing = "ping"
-	EventPong = "pong"
+	EventAuth   = "auth"
+	EventPing   = "ping"
+	EventPong   = "pong"
+	EventError  = "error"
+	EventKicked = "kicked"
+	EventResume = "resume"
+	// 房间事件，游戏服务通过 system 控制消息管理成员，客户端只能加入/离开公开房间
+	EventRoomJoin  = "room_join"
+	EventRoomLeave = "room_leave"
+	// 多播后回报给游戏服务的不在线用户
+	EventOfflineUsers = "offline_users"
+	// 全服公告，发给全部已认证的会话
+	EventAnnounce = "announce"
+)
+
+const (
+	tickInterval = 30 * time.Second
+)
+
+// 踢下线原因
+const (
+	KickLoginElsewhere = "login_elsewhere" // 在其他设备登录或超出会话上限
 )
 
 type Server struct {
@@ -30,15 +50,34 @@ type Server struct {
 
 	engine gnet.Engine
 
-	// 网关配置
-	cfg *dto.GatewayConfig
-
 	// 上下文
 	ctx    context.Context
 	cancel context.CancelFunc
+	// writeLoop 退出后关闭
+	writeDone chan struct{}
 
 	// 已认证连接
 	connMgr *conn.Manager
+	// 多端登录配置
+	login atomic.Pointer[loginPolicy]
+	// 游戏服务成员索引
+	members *conn.Members
+	// 房间成员
+	rooms *conn.Rooms
+	// 公开房间配置
+	roomPolicy atomic.Pointer[roomPolicy]
+	// 管理接口配置
+	admin atomic.Pointer[adminPolicy]
+	// 断线重连
+	replay *conn.Replay
+	// 等待游戏服务应答的请求
+	inflight *inflight
+	// 请求超时配置
+	request atomic.Pointer[requestPolicy]
+	// 用户生命周期事件订阅
+	lifecycle atomic.Pointer[lifecyclePolicy]
+	// 队列已满丢弃的生命周期事件数
+	lifecycleDropped atomic.Uint64
 
 	// 未认证连接
 	unauthConn sync.Map
@@ -55,47 +94,111 @@ type Server struct {
 
 	// 缓存提供器
 	cache cache.Provider
-	// 访问限制器
-	limiter *rate.Limiter
+	// 用户认证器
+	auth *Authenticator
+	// 限流器
+	limits atomic.Pointer[limits]
+	// IP 黑白名单
+	ipFilter atomic.Pointer[ipFilter]
+	// 单 IP 在线连接数
+	ipConns *ipConns
+	// 握手校验规则
+	handshake atomic.Pointer[handshakePolicy]
+	// 压缩配置，为 nil 时不协商压缩
+	compress atomic.Pointer[compressPolicy]
+	// 心跳与超时配置
+	heartbeat atomic.Pointer[heartbeatPolicy]
+	// 连接超时定时器
+	wheel *timewheel.TimeWheel
+	// 下行发送队列配置
+	outbound atomic.Pointer[outboundPolicy]
+	// 发送队列丢弃统计
+	outStats outboundCounters
 }
 
-func NewWsServer(cfg *dto.GatewayConfig, ce cache.Provider, tcpAddr string) gnet.EventHandler {
+func NewWsServer(gateway *dto.Gateway, ce cache.Provider, tcpAddr string) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Server{
-		ctx:     ctx,
-		cancel:  cancel,
-		cfg:     cfg,
-		cache:   ce,
-		tcpAddr: tcpAddr,
-
-		connMgr: conn.NewManager(),
-
-		inMsg:   make(chan *dto.CommonReq, 1024),
-		outMsg:  make(chan *dto.CommonRes, 1024),
-		limiter: rate.NewLimiter(rate.Limit(100), 300),
+	w := &Server{
+		ctx:       ctx,
+		cancel:    cancel,
+		writeDone: make(chan struct{}),
+		cache:     ce,
+		auth:      NewAuthenticator(ce),
+		tcpAddr:   tcpAddr,
+
+		connMgr:  conn.NewManager(),
+		members:  conn.NewMembers(),
+		rooms:    conn.NewRooms(),
+		replay:   conn.NewReplay(gateway.Config.Resume.Size, time.Duration(gateway.Config.Resume.Ttl)*time.Second),
+		inflight: newInflight(),
+
+		inMsg:  make(chan *dto.CommonReq, 1024),
+		outMsg: make(chan *dto.CommonRes, 1024),
+
+		ipConns: newIpConns(),
+		wheel:   timewheel.New(wheelTick),
+	}
+	w.login.Store(newLoginPolicy(gateway.Config.Login))
+	w.limits.Store(newLimits(gateway.Config.RateLimit))
+	w.ipFilter.Store(newIpFilter(gateway.Config.IpFilter))
+	w.handshake.Store(newHandshakePolicy(gateway.Config.Handshake))
+	w.compress.Store(newCompressPolicy(gateway.Config.Compression))
+	w.heartbeat.Store(newHeartbeatPolicy(gateway.Config.Heartbeat))
+	w.outbound.Store(newOutboundPolicy(gateway.Config.Outbound))
+	w.roomPolicy.Store(newRoomPolicy(gateway.Config.Rooms))
+	w.admin.Store(newAdminPolicy(gateway.Config.Admin))
+	w.request.Store(newRequestPolicy(gateway.Config.Request))
+	w.lifecycle.Store(newLifecyclePolicy(gateway.Config.Lifecycle))
+	if err := w.auth.Reload(gateway.Config.Jwt); err != nil {
+		logger.Log.Errorf("jwt keys loading error: %+v", err)
 	}
-}
-
-func (w *Server) OnBoot(eng gnet.Engine) gnet.Action {
-	w.engine = eng
-	logger.Log.Info("\033[0;32;40mGateway WS Server Started\033[0m")
-
 	w.tcpSrv = tcp.NewTcpServer(
 		w.tcpAddr,
 		w.cache,
-		w.cfg.GameList,
+		gateway.Config.GameList,
+		gateway.Config.Link,
 		w.inMsg,
 		w.outMsg,
 	)
+	gateway.OnChanged(w.reload)
+	return w
+}
+
+// reload 配置中心推送新配置后热更新，无需重启 gnet
+func (w *Server) reload(cfg *dto.GatewayConfig) {
+	if err := w.auth.Reload(cfg.Jwt); err != nil {
+		logger.Log.Errorf("jwt keys reloading error: %+v", err)
+	}
+	w.login.Store(newLoginPolicy(cfg.Login))
+	w.limits.Store(newLimits(cfg.RateLimit))
+	w.handshake.Store(newHandshakePolicy(cfg.Handshake))
+	w.compress.Store(newCompressPolicy(cfg.Compression))
+	w.heartbeat.Store(newHeartbeatPolicy(cfg.Heartbeat))
+	w.outbound.Store(newOutboundPolicy(cfg.Outbound))
+	w.roomPolicy.Store(newRoomPolicy(cfg.Rooms))
+	w.admin.Store(newAdminPolicy(cfg.Admin))
+	w.request.Store(newRequestPolicy(cfg.Request))
+	w.lifecycle.Store(newLifecyclePolicy(cfg.Lifecycle))
+	w.tcpSrv.SetLink(cfg.Link)
+	f := newIpFilter(cfg.IpFilter)
+	w.ipFilter.Store(f)
+	w.dropDenied(f)
+}
+
+func (w *Server) OnBoot(eng gnet.Engine) gnet.Action {
+	w.engine = eng
+	logger.Log.Info("\033[0;32;40mGateway WS Server Started\033[0m")
 
 	go w.tcpSrv.Run()
 	go w.writeLoop()
+	go w.wheel.Run(w.ctx)
 
 	return gnet.None
 }
 
 // writeLoop 消息发送循环
 func (w *Server) writeLoop() {
+	defer close(w.writeDone)
 	for {
 		select {
 		case msg, ok := <-w.outMsg:
@@ -113,65 +216,210 @@ func (w *Server) writeLoop() {
 	}
 }
 
+// OnShutdown 在全部事件循环与连接关闭后调用
+//
+// 事件循环与 writeLoop 都退出后才关闭 inMsg，outMsg 仍可能被 tcp 服务的读取协程写入，不关闭。
+func (w *Server) OnShutdown(eng gnet.Engine) {
+	w.tcpSrv.Stop()
+	logger.Log.Info("\033[0;33;40mGateway Ws Server Will Be Shutdown!\033[0m")
+	// 停止 writeLoop 与时间轮
+	w.cancel()
+	<-w.writeDone
+	close(w.inMsg)
+}
+
 // dispatch 消息分发
 func (w *Server) dispatch(msg *dto.CommonRes) {
+	if msg.System {
+		w.handleControl(msg)
+		return
+	}
+	if msg.Room != "" {
+		w.publish(msg)
+		return
+	}
+	if len(msg.UserIds) > 0 {
+		w.multicast(msg)
+		return
+	}
+	if msg.UserId != 0 {
+		if w.resolve(msg) {
+			w.sendToUser(msg)
+		}
+		return
+	}
 	payload, err := json.Marshal(msg)
 	if err != nil {
 		logger.Log.Error(err)
 		return
 	}
-	if msg.UserId != 0 {
-		w.sendToUser(msg.UserId, payload)
-	} else {
-		w.broadcast(msg.Server, payload)
+	w.broadcast(msg.Server, payload)
+}
+
+// handleControl 处理游戏服务发给网关的控制消息
+func (w *Server) handleControl(msg *dto.CommonRes) {
+	switch msg.Event {
+	case EventRoomJoin, EventRoomLeave:
+		w.handleRoomControl(msg)
+	case EventAnnounce:
+		w.handleAnnounceControl(msg)
+	default:
+		logger.Log.Warnf("game server %s sent unknown control event %s", msg.Server, msg.Event)
 	}
 }
 
-// sendToUser 发送给用户
-func (w *Server) sendToUser(uid int64, payload []byte) {
-	client, ok := w.connMgr.Get(uid)
+// sendToUser 发送给用户，在线或等待重连的用户按网关序号记录，重连后补发
+func (w *Server) sendToUser(msg *dto.CommonRes) {
+	build := func(seq int64) []byte {
+		msg.GSeq = seq
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			logger.Log.Error(err)
+			return nil
+		}
+		return payload
+	}
+	send := func(payload []byte) {
+		w.writeToUser(msg.UserId, payload)
+	}
+	if o, ok := w.replay.Get(msg.UserId); ok {
+		o.Push(build, send)
+		return
+	}
+	if payload := build(0); payload != nil {
+		send(payload)
+	}
+}
+
+// writeToUser 加入用户全部会话的发送队列
+func (w *Server) writeToUser(uid int64, payload []byte) {
+	sessions, ok := w.connMgr.Get(uid)
 	if !ok {
 		return
 	}
-	err := wsutil.WriteServerBinary(client.Conn, payload)
-	if err != nil {
-		logger.Log.Error(err)
+	frame := newOutFrame(payload)
+	for _, client := range sessions {
+		w.enqueue(client, frame)
 	}
 }
 
-// broadcast 广播
-func (w *Server) broadcast(server string, payload []byte) {
-	for _, item := range w.connMgr.Snapshot() {
-		client := item.Client
-		wsc := client.Conn.Context().(*wsCodec)
-		// 判断用户所处的服务是否一致
-		if wsc.String("server") != server {
+// multicast 发给 UserIds 中每个用户的全部会话，帧只编码一次，多播消息不记录网关序号
+//
+// 游戏服务设置 ReportOffline 时，按原 seq 回报不在线的用户，全部在线时用户列表为空。
+func (w *Server) multicast(msg *dto.CommonRes) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		logger.Log.Error(err)
+		return
+	}
+	frame := newOutFrame(payload)
+	var offline []int64
+	seen := make(map[int64]struct{}, len(msg.UserIds))
+	for _, uid := range msg.UserIds {
+		// 重复的用户只发送一次
+		if _, ok := seen[uid]; ok {
 			continue
 		}
-		if err := wsutil.WriteServerBinary(client.Conn, payload); err != nil {
-			logger.Log.Error(err)
+		seen[uid] = struct{}{}
+		sessions, ok := w.connMgr.Get(uid)
+		if !ok {
+			offline = append(offline, uid)
+			continue
+		}
+		for _, client := range sessions {
+			w.enqueue(client, frame)
 		}
 	}
+	if !msg.ReportOffline {
+		return
+	}
+	// writeLoop 是 outMsg 唯一的消费者，队列已满时丢弃回报，不阻塞下行消息
+	select {
+	case w.inMsg <- &dto.CommonReq{
+		Server:  msg.Server,
+		Event:   EventOfflineUsers,
+		Seq:     msg.Seq,
+		UserIds: offline,
+		System:  true,
+		// 回报给发出多播的实例
+		Instance: msg.Instance,
+	}:
+	default:
+		logger.Log.Warnf("drop offline users report to game server %s seq %d due to full queue", msg.Server, msg.Seq)
+	}
 }
 
-func (w *Server) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
-	if !w.limiter.Allow() {
-		logger.Log.Info("rate limit exceeded")
-		return nil, gnet.Close
+// broadcast 广播给绑定到 server 的全部会话，帧只编码一次
+func (w *Server) broadcast(server string, payload []byte) {
+	frame := newOutFrame(payload)
+	for _, client := range w.members.Snapshot(server) {
+		w.enqueue(client, frame)
 	}
+}
+
+func (w *Server) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 	wc := NewWsCodec()
 	c.SetContext(wc)
 
-	// 放入未认证集合
+	ip := remoteIP(c)
+	f := w.ipFilter.Load()
+	if !f.Allowed(ip) {
+		logger.Log.Infof("ip[%s] denied", ip)
+		wc.closeReason.Store(uint32(CloseIpDenied))
+		return []byte("HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n"), gnet.Close
+	}
+	if !w.limits.Load().ip.Allow(ip) {
+		logger.Log.Infof("ip[%s] rate limit exceeded", ip)
+		wc.closeReason.Store(uint32(CloseRateLimited))
+		return []byte("HTTP/1.1 429 Too Many Requests\r\nContent-Length: 0\r\n\r\n"), gnet.Close
+	}
+	if !w.ipConns.Acquire(ip, f.maxConns) {
+		logger.Log.Infof("ip[%s] too many connections", ip)
+		wc.closeReason.Store(uint32(CloseRateLimited))
+		return []byte("HTTP/1.1 429 Too Many Requests\r\nContent-Length: 0\r\n\r\n"), gnet.Close
+	}
+	wc.ip = ip
+
+	// 放入未认证集合，认证超时后关闭
 	w.unauthConn.Store(c, wc)
+	w.watch(c, wc)
 	return nil, gnet.None
 }
 
+// remoteIP 返回连接的对端 IP
+func remoteIP(c gnet.Conn) string {
+	addr := c.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
 func (w *Server) OnClose(c gnet.Conn, err error) gnet.Action {
 	wsc := c.Context().(*wsCodec)
-	uid := wsc.UID()
-	if uid != 0 {
-		w.connMgr.Remove(uid)
+	reason := CloseReason(wsc.closeReason.Load())
+	logger.Log.Infof("conn[%v] closed, uid %d reason %s err %v", c.RemoteAddr().String(), wsc.UID(), reason, err)
+	w.unwatch(wsc)
+	if wsc.ip != "" {
+		w.ipConns.Release(wsc.ip)
+	}
+	w.unauthConn.Delete(c)
+	if wsc.client != nil {
+		w.members.Unbind(wsc.client)
+		w.rooms.Drop(wsc.client)
+		w.connMgr.Remove(wsc.UID(), wsc.client)
+		w.userOffline(wsc, reason)
+		// 全部会话断开后保留下行消息等待重连
+		if _, ok := w.connMgr.Get(wsc.UID()); !ok {
+			w.replay.Release(wsc.UID())
+		}
 	}
 	return gnet.None
 }
@@ -181,30 +429,124 @@ func (w *Server) OnTraffic(c gnet.Conn) gnet.Action {
 	if wsc.readBufferBytes(c) == gnet.Close {
 		return gnet.Close
 	}
-	ok, action := wsc.upgrade(c)
-	if !ok {
-		return action
+	if !wsc.upgraded.Load() {
+		ok, action := wsc.upgrade(c, w.newUpgrader(wsc))
+		if !ok {
+			if action == gnet.Close {
+				return closeConn(c, CloseHandshakeFailed)
+			}
+			return action
+		}
+		// 握手阶段已认证
+		if wsc.claims != nil {
+			if err := w.authorize(c, wsc, wsc.claims, nil); err != nil {
+				w.replyError(c, &dto.CommonReq{Server: ServerSystem, Event: EventAuth}, dto.CodeLoginConflict, "")
+				return closeConn(c, CloseLoginConflict)
+			}
+			w.replyAuth(c, wsc, EventAuth, 0, dto.CodeOK, w.lastSeq(wsc.UID()))
+		}
 	}
 	return w.handleMessages(c, wsc)
 }
 
-func (w *Server) bindUser(c gnet.Conn, uid int64) bool {
-	wsc := c.Context().(*wsCodec)
-	client := conn.NewClient(uid, c)
-	w.connMgr.Set(uid, client)
-	wsc.Bind(uid)
-	return true
+// loginPolicy 多端登录配置，配置变更后对之后的登录生效
+type loginPolicy struct {
+	policy      conn.LoginPolicy
+	maxSessions int
+}
+
+func newLoginPolicy(cfg dto.LoginConfig) *loginPolicy {
+	return &loginPolicy{policy: conn.LoginPolicy(cfg.Policy), maxSessions: cfg.MaxSessions}
+}
+
+// bindUser 按登录策略绑定用户会话，被挤下线的旧会话收到 system/kicked 通知后关闭
+func (w *Server) bindUser(c gnet.Conn, wsc *wsCodec, user *UserClaims) error {
+	client := conn.NewClient(user.User.Id, newSessionId(), user.User.DeviceId, c)
+	p := w.login.Load()
+	kicked, err := w.connMgr.Add(client, p.policy, p.maxSessions)
+	if err != nil {
+		return err
+	}
+	wsc.Bind(user.User.Id)
+	wsc.client = client
+	for _, old := range kicked {
+		w.kick(old, KickLoginElsewhere)
+	}
+	return nil
+}
+
+// kick 通知会话被踢下线并关闭连接
+func (w *Server) kick(client *conn.Client, reason string) {
+	logger.Log.Infof("kick session, uid %d session %s reason %s", client.UID, client.SessionId, reason)
+	data, _ := json.Marshal(dto.KickedRes{Reason: reason})
+	payload, _ := json.Marshal(dto.CommonRes{
+		Server: ServerSystem,
+		Event:  EventKicked,
+		Code:   dto.CodeOK,
+		Msg:    reason,
+		Data:   data,
+	})
+	wsc, ok := client.Conn.Context().(*wsCodec)
+	if !ok {
+		_ = client.Conn.Close()
+		return
+	}
+	frame, err := newOutFrame(payload).For(wsc)
+	if err != nil {
+		logger.Log.Error(err)
+		asyncClose(client.Conn, CloseKicked)
+		return
+	}
+	asyncClose(client.Conn, CloseKicked, frame)
+}
+
+// authorize 认证通过后绑定用户并移出未认证集合，outbox 为重连时恢复的 Outbox，新登录时为 nil
+func (w *Server) authorize(c gnet.Conn, wsc *wsCodec, user *UserClaims, outbox *conn.Outbox) error {
+	// 连接绑定
+	if err := w.bindUser(c, wsc, user); err != nil {
+		return err
+	}
+	// 签发重连凭证
+	wsc.resumeToken = newSessionId()
+	w.replay.Issue(wsc.resumeToken, conn.ResumeToken{
+		UID:      user.User.Id,
+		DeviceId: user.User.DeviceId,
+		TokenId:  user.ID,
+	}, outbox)
+
+	// 移出未认证集合
+	w.unauthConn.Delete(c)
+	w.userOnline(wsc.client)
+	return nil
 }
 
 func (w *Server) handleMessages(c gnet.Conn, wsc *wsCodec) gnet.Action {
 	messages, err := wsc.Decode(c)
 	if err != nil {
-		return gnet.Close
+		return closeConn(c, decodeCloseReason(err))
 	}
 	for _, message := range messages {
 		var msg dto.CommonReq
 		if err = json.Unmarshal(message.Payload, &msg); err != nil {
 			logger.Log.Errorf("message parsing error: %+v", err)
+			w.replyError(c, &dto.CommonReq{}, dto.CodeBadRequest, err.Error())
+			continue
+		}
+		// 心跳之外的消息刷新空闲时间
+		if msg.Server != ServerSystem || msg.Event != EventPing {
+			wsc.lastActive.Store(time.Now().UnixNano())
+		}
+		if !w.allowMessage(wsc, &msg) {
+			w.replyError(c, &msg, dto.CodeRateLimited, "")
+			if w.rateViolated(wsc) {
+				logger.Log.Infof("conn[%v] too many rate limit violations", c.RemoteAddr().String())
+				if wsc.client != nil {
+					// 关闭帧排在已加入发送队列的回复之后
+					asyncClose(c, CloseRateLimited)
+					return gnet.None
+				}
+				return closeConn(c, CloseRateLimited)
+			}
 			continue
 		}
 
@@ -212,93 +554,173 @@ func (w *Server) handleMessages(c gnet.Conn, wsc *wsCodec) gnet.Action {
 		case ServerSystem: // 系统服务
 			switch msg.Event {
 			case EventAuth: // 用户校验事件
+				if wsc.client != nil {
+					w.replyError(c, &msg, dto.CodeBadRequest, "already authenticated")
+					continue
+				}
 				var req dto.AuthReq
 				err = json.Unmarshal(msg.Data, &req)
 				if err != nil {
 					logger.Log.Errorf("json.Unmarshal error: %+v", err)
-					return gnet.Close
+					w.replyError(c, &msg, dto.CodeBadRequest, err.Error())
+					return closeConn(c, CloseBadRequest)
 				}
-				user := Intercept(w.cache, req.Token)
-				if user == nil {
-					return gnet.Close
+				user, err := w.auth.Intercept(req.Token)
+				if err != nil {
+					logger.Log.Infof("conn[%v] auth failed: %v", c.RemoteAddr().String(), err)
+					w.replyError(c, &msg, dto.CodeUnauthenticated, err.Error())
+					return closeConn(c, CloseUnauthenticated)
 				}
-				// 连接绑定
-				wsc.Bind(user.User.Id)
-				w.bindUser(c, user.User.Id)
-
-				// 移出未认证集合
-				w.unauthConn.Delete(c)
-				continue
+				if err = w.authorize(c, wsc, user, nil); err != nil {
+					w.replyError(c, &msg, dto.CodeLoginConflict, "")
+					return closeConn(c, CloseLoginConflict)
+				}
+				w.replyAuth(c, wsc, EventAuth, msg.Seq, dto.CodeOK, w.lastSeq(wsc.UID()))
 			case EventPing: // 用户心跳事件
 				if wsc.UID() == 0 {
-					return gnet.Close
+					w.replyError(c, &msg, dto.CodeUnauthenticated, "")
+					return closeConn(c, CloseUnauthenticated)
 				}
-				w.handlePing(wsc.UID())
-				continue
+				w.handlePing(c, wsc)
+			case EventRoomJoin, EventRoomLeave: // 公开房间
+				if wsc.UID() == 0 {
+					w.replyError(c, &msg, dto.CodeUnauthenticated, "")
+					return closeConn(c, CloseUnauthenticated)
+				}
+				w.handleRoom(c, wsc, &msg)
+			case EventResume: // 断线重连事件
+				if action := w.handleResume(c, wsc, &msg); action != gnet.None {
+					return action
+				}
+			default:
+				w.replyError(c, &msg, dto.CodeBadRequest, "unknown event")
 			}
+			continue
 		default:
-			if wsc.uid == 0 {
-				return gnet.Close
+			if wsc.UID() == 0 {
+				w.replyError(c, &msg, dto.CodeUnauthenticated, "")
+				return closeConn(c, CloseUnauthenticated)
+			}
+			if !w.tcpSrv.IsAllowedGame(msg.Server) {
+				w.replyError(c, &msg, dto.CodeUnknownServer, "")
+				continue
+			}
+			// 重连宽限期内的消息由 tcp 服务暂存
+			if !w.tcpSrv.Available(msg.Server) {
+				w.replyError(c, &msg, dto.CodeGameOffline, "")
+				continue
 			}
 			// 绑定服务
-			wsc.Set("server", msg.Server)
+			if from := wsc.String("server"); from != msg.Server {
+				wsc.Set("server", msg.Server)
+				w.members.Bind(wsc.client, msg.Server)
+				w.userBind(wsc, from, msg.Server)
+			}
 		}
 
 		msg.UserId = wsc.UID()
+		w.track(&msg)
 		w.inMsg <- &msg
 	}
+	if wsc.peerClose != nil {
+		return wsc.ackClose(c)
+	}
 	return gnet.None
 }
 
-func (w *Server) handlePing(uid int64) {
-	client, ok := w.connMgr.Get(uid)
-	if !ok {
+// reply 回复当前连接，已认证的会话经发送队列发送，与其他下行消息保持先后顺序
+func (w *Server) reply(c gnet.Conn, res *dto.CommonRes) {
+	payload, err := json.Marshal(res)
+	if err != nil {
+		logger.Log.Error(err)
 		return
 	}
-	client.LastHeartbeat = time.Now().Unix()
-	w.connMgr.Set(uid, client)
+	w.send(c, payload)
+}
+
+// send 发送给当前连接，未认证的连接没有发送队列，在事件循环中直接写入
+func (w *Server) send(c gnet.Conn, payload []byte) {
+	if wsc, ok := c.Context().(*wsCodec); ok && wsc.client != nil {
+		w.enqueue(wsc.client, newOutFrame(payload))
+		return
+	}
+	if err := writeFrame(c, newOutFrame(payload)); err != nil {
+		logger.Log.Error(err)
+	}
+}
+
+// replyError 回复错误码，回显请求的 server、event 与 seq，无法解析的消息使用 system/error
+func (w *Server) replyError(c gnet.Conn, req *dto.CommonReq, code int, msg string) {
+	res := &dto.CommonRes{
+		Server: req.Server,
+		Event:  req.Event,
+		Seq:    req.Seq,
+		Code:   code,
+		Msg:    msg,
+	}
+	if res.Server == "" {
+		res.Server = ServerSystem
+		res.Event = EventError
+	}
+	if res.Msg == "" {
+		res.Msg = dto.CodeMsg(code)
+	}
+	w.reply(c, res)
+}
+
+// replyAuth 回复认证/重连结果，握手阶段认证时 seq 为 0
+func (w *Server) replyAuth(c gnet.Conn, wsc *wsCodec, event string, seq int64, code int, lastSeq int64) {
+	data, _ := json.Marshal(dto.AuthRes{
+		UserId:      wsc.UID(),
+		SessionId:   wsc.SessionId(),
+		ResumeToken: wsc.resumeToken,
+		LastSeq:     lastSeq,
+	})
+	w.reply(c, &dto.CommonRes{
+		Server: ServerSystem,
+		Event:  event,
+		Seq:    seq,
+		Code:   code,
+		Msg:    dto.CodeMsg(code),
+		Data:   data,
+	})
+}
+
+// lastSeq 返回用户当前最大网关序号
+func (w *Server) lastSeq(uid int64) int64 {
+	if o, ok := w.replay.Get(uid); ok {
+		return o.Seq()
+	}
+	return 0
+}
+
+func (w *Server) handlePing(c gnet.Conn, wsc *wsCodec) {
+	wsc.client.Touch()
 
 	// pong
-	res := dto.CommonRes{
+	w.reply(c, &dto.CommonRes{
 		Server: ServerSystem,
 		Event:  EventPong,
 		Code:   0,
-	}
-	payload, _ := json.Marshal(res)
-	w.sendToUser(uid, payload)
-	return
+	})
 }
 
 func (w *Server) OnTick() (delay time.Duration, action gnet.Action) {
-	// 定时踢掉死链接
-	now := time.Now().Unix()
-	w.connMgr.Range(func(uid int64, cli *conn.Client) {
-		if now-cli.LastHeartbeat > 30 {
-			logger.Log.Infof("clear heartbeat timeout client, uid %d", uid)
-			cli.Conn.Close()
-		}
-	})
+	// 清理过期的重连凭证
+	w.replay.Sweep()
 
-	// 定时踢掉未认证连接
-	w.unauthConn.Range(func(key, value any) bool {
-		conn := key.(gnet.Conn)
-		ws := value.(*wsCodec)
-		if now-ws.ConnectTime > 5 { // 5秒未 auth
-			logger.Log.Infof("clear unauthenticated client")
-			conn.Close()
-			w.unauthConn.Delete(key)
-		}
-		return true
-	})
+	// 回收闲置的限流器
+	l := w.limits.Load()
+	l.user.Sweep(limiterIdle)
+	l.ip.Sweep(limiterIdle)
 
-	logger.Log.Infof("\033[0;33;40m[connected-count=%v]\033[0m", w.engine.CountConnections())
-	return 30 * time.Second, gnet.None
-}
+	// JWKS 文件变化后重新加载
+	if err := w.auth.Refresh(); err != nil {
+		logger.Log.Errorf("jwks refreshing error: %+v", err)
+	}
 
-func (w *Server) OnShutdown(eng gnet.Engine) {
-	close(w.inMsg)
-	close(w.outMsg)
-	w.tcpSrv.Stop()
-	logger.Log.Info("\033[0;33;40mGateway Ws Server Will Be Shutdown!\033[0m")
-	w.cancel()
+	stats := w.OutboundStats()
+	logger.Log.Infof("\033[0;33;40m[connected-count=%v] [dropped-oldest=%v dropped-newest=%v slow-disconnected=%v] [game-instances=%v] [lifecycle-dropped=%v]\033[0m",
+		w.engine.CountConnections(), stats.DroppedOldest, stats.DroppedNewest, stats.Disconnected, w.tcpSrv.InstanceCounts(), w.lifecycleDropped.Load())
+	return tickInterval, gnet.None
 }
@@ -0,0 +1,189 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aluka-7/game-gateway/conn"
+	"github.com/aluka-7/game-gateway/dto"
+	"github.com/panjf2000/gnet/v2"
+)
+
+// echoServer 使用网关的升级与编解码流程回显消息
+type echoServer struct {
+	gnet.BuiltinEventEngine
+	srv    *Server
+	engine chan gnet.Engine
+}
+
+func (e *echoServer) OnBoot(eng gnet.Engine) gnet.Action {
+	e.engine <- eng
+	return gnet.None
+}
+
+func (e *echoServer) OnOpen(c gnet.Conn) ([]byte, gnet.Action) {
+	c.SetContext(NewWsCodec())
+	return nil, gnet.None
+}
+
+func (e *echoServer) OnTraffic(c gnet.Conn) gnet.Action {
+	wsc := c.Context().(*wsCodec)
+	if wsc.readBufferBytes(c) == gnet.Close {
+		return gnet.Close
+	}
+	if !wsc.upgraded.Load() {
+		if ok, action := wsc.upgrade(c, e.srv.newUpgrader(wsc)); !ok {
+			return action
+		}
+	}
+	messages, err := wsc.Decode(c)
+	if err != nil {
+		return gnet.Close
+	}
+	for _, m := range messages {
+		if string(m.Payload) == "bye" {
+			return closeConn(c, CloseUnauthenticated)
+		}
+		if err = writeFrame(c, newOutFrame(m.Payload)); err != nil {
+			return gnet.Close
+		}
+	}
+	if wsc.peerClose != nil {
+		return wsc.ackClose(c)
+	}
+	return gnet.None
+}
+
+func startEchoServer(t *testing.T, cfg dto.CompressionConfig) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	srv := &Server{}
+	srv.handshake.Store(newHandshakePolicy(dto.HandshakeConfig{}))
+	srv.compress.Store(newCompressPolicy(cfg))
+	e := &echoServer{srv: srv, engine: make(chan gnet.Engine, 1)}
+	go func() {
+		_ = gnet.Run(e, "tcp://"+addr)
+	}()
+	select {
+	case eng := <-e.engine:
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			_ = eng.Stop(ctx)
+		})
+	case <-time.After(3 * time.Second):
+		t.Fatal("echo server not started")
+	}
+	return "ws://" + addr
+}
+
+// benchConn 写入后立即完成的 gnet.Conn
+type benchConn struct {
+	gnet.Conn
+	ctx any
+}
+
+func (c *benchConn) Context() any          { return c.ctx }
+func (c *benchConn) OutboundBuffered() int { return 0 }
+func (c *benchConn) AsyncWritev(bs [][]byte, cb gnet.AsyncCallback) error {
+	return cb(c, nil)
+}
+
+// BenchmarkBroadcast 10 万个连接分布在 20 个游戏服务，每次广播给其中一个服务的 5000 个成员
+func BenchmarkBroadcast(b *testing.B) {
+	const conns, games = 100000, 20
+	srv := &Server{connMgr: conn.NewManager(), members: conn.NewMembers()}
+	srv.outbound.Store(newOutboundPolicy(dto.OutboundConfig{}))
+	for i := 0; i < conns; i++ {
+		wsc := NewWsCodec()
+		client := conn.NewClient(int64(i+1), "", "", &benchConn{ctx: wsc})
+		wsc.client = client
+		_, _ = srv.connMgr.Add(client, conn.PolicyKick, 0)
+		srv.members.Bind(client, "game-"+strconv.Itoa(i%games))
+	}
+	payload := []byte(`{"server":"game-0","event":"tick","code":0,"data":{"round":1}}`)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		srv.broadcast("game-"+strconv.Itoa(i%games), payload)
+	}
+}
+
+func TestMulticast(t *testing.T) {
+	srv := newRoomServer()
+	srv.inMsg = make(chan *dto.CommonReq, 1)
+	a, _ := newRoomClient(srv, 10001)
+	b, _ := newRoomClient(srv, 10002)
+	c, _ := newRoomClient(srv, 10003)
+
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: "win", Seq: 7, UserIds: []int64{10001, 10002, 10002, 10004}, ReportOffline: true, Instance: "wingo-2"})
+	if len(a.writes) != 1 || len(b.writes) != 1 || len(c.writes) != 0 || !bytes.Equal(a.writes[0], b.writes[0]) {
+		t.Fatalf("multicast: a=%d b=%d c=%d", len(a.writes), len(b.writes), len(c.writes))
+	}
+	report := <-srv.inMsg
+	if !report.System || report.Event != EventOfflineUsers || report.Seq != 7 || len(report.UserIds) != 1 || report.UserIds[0] != 10004 || report.Instance != "wingo-2" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	// 未要求回报时不回报
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: "win", UserIds: []int64{10004}})
+	if len(srv.inMsg) != 0 {
+		t.Fatal("unexpected report")
+	}
+
+	// 队列已满时丢弃回报，不阻塞
+	srv.inMsg <- &dto.CommonReq{}
+	srv.dispatch(&dto.CommonRes{Server: "wingo", Event: "win", UserIds: []int64{10004}, ReportOffline: true})
+	if len(srv.inMsg) != 1 {
+		t.Fatal("report queued over capacity")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	gateway := &dto.Gateway{Config: dto.GatewayConfig{GameList: []string{"wingo"}}}
+	srv := NewWsServer(gateway, nil, "127.0.0.1:0")
+	go srv.tcpSrv.Run()
+	go srv.writeLoop()
+
+	srv.OnShutdown(gnet.Engine{})
+	if srv.ctx.Err() == nil {
+		t.Fatal("context not canceled")
+	}
+	select {
+	case _, ok := <-srv.inMsg:
+		if ok {
+			t.Fatal("unexpected message")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("inMsg not closed")
+	}
+}
+
+func TestLoginPolicyReload(t *testing.T) {
+	gateway := &dto.Gateway{Path: "gateway", Config: dto.GatewayConfig{Login: dto.LoginConfig{Policy: "reject"}}}
+	srv := NewWsServer(gateway, nil, "127.0.0.1:0")
+	defer srv.tcpSrv.Stop()
+	if p := srv.login.Load(); p.policy != conn.PolicyReject {
+		t.Fatalf("policy = %q", p.policy)
+	}
+
+	// 热更新后替换快照，旧快照不受影响
+	old := srv.login.Load()
+	gateway.Changed(map[string]string{"gateway": `{"login":{"policy":"multi","maxSessions":3}}`})
+	if p := srv.login.Load(); p.policy != conn.PolicyMulti || p.maxSessions != 3 {
+		t.Fatalf("reloaded policy = %+v", p)
+	}
+	if old.policy != conn.PolicyReject {
+		t.Fatal("old snapshot modified")
+	}
+}